@@ -0,0 +1,401 @@
+// Package api serves a fleet over HTTP with JSON requests and responses
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+/*
+
+	Endpoints
+
+	GET  /owners                                      every owner with their engines
+	POST /owners                                      {"name":"Donne","id":1}, without an ID the next free number is used
+	GET  /owners/{id}                                 one owner, {id} is a number or a UUID
+	GET  /engines                                     every engine of every owner
+	POST /owners/{id}/engines                         {"type":"gas","mpg":25,"gallons":15}, an "owner" field must be the {id} owner
+	GET  /owners/{id}/engines/{index}/miles-left
+	GET  /owners/{id}/engines/{index}/can-drive?miles=120
+	POST /can-drive                                   {"engine":{"type":"electric","mpkwh":3,"kwh":10},"miles":50}, nothing is stored
+
+	Errors are {"error":"..."} with 400 for bad input, 404 for unknown owners or engines and 409 for ID conflicts
+	A change that cannot be saved is undone and answered with 500
+
+*/
+
+// maxBody bounds request bodies, engines and owners are small
+const maxBody = 1 << 20
+
+// Server handles the endpoints above for one registry, it is safe to use from several go routines
+type Server struct {
+	registry *fleet.Registry
+	save     func() error // called after every change, nil keeps changes in memory only
+	mu       sync.Mutex   // one change and its save at a time, so saves land in the order the changes were made
+	mux      *http.ServeMux
+}
+
+// New returns a server for registry, save is called after every change and may be nil
+func New(registry *fleet.Registry, save func() error) *Server {
+	s := &Server{registry: registry, save: save, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /owners", s.listOwners)
+	s.mux.HandleFunc("POST /owners", s.createOwner)
+	s.mux.HandleFunc("GET /owners/{id}", s.getOwner)
+	s.mux.HandleFunc("GET /engines", s.listEngines)
+	s.mux.HandleFunc("POST /owners/{id}/engines", s.createEngine)
+	s.mux.HandleFunc("GET /owners/{id}/engines/{index}/miles-left", s.milesLeft)
+	s.mux.HandleFunc("GET /owners/{id}/engines/{index}/can-drive", s.canDrive)
+	s.mux.HandleFunc("POST /can-drive", s.canDriveAdHoc)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Owner is an owner as the API writes it
+type Owner struct {
+	vehicle.EngineOwner
+	Engines []Engine `json:"engines"`
+}
+
+// Engine is one engine as the API writes it, its fields with a "type" plus the range computed from them
+type Engine struct {
+	Owner     vehicle.OwnerID `json:"owner_id"`
+	Index     int             `json:"index"`
+	Engine    vehicle.Params  `json:"engine"`
+	MilesLeft *float64        `json:"miles_left,omitempty"` // nil when MilesLeft failed, Error says why
+	Error     string          `json:"error,omitempty"`
+}
+
+// MilesLeft is the response of the miles-left endpoint
+type MilesLeft struct {
+	MilesLeft float64 `json:"miles_left"`
+}
+
+// CanDriveRequest is the body of POST /can-drive
+type CanDriveRequest struct {
+	Engine vehicle.Params `json:"engine"`
+	Miles  float64        `json:"miles"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) listOwners(w http.ResponseWriter, r *http.Request) {
+	owners := []Owner{}
+	for _, entry := range s.registry.List() {
+		owner, err := ownerOf(entry)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		owners = append(owners, owner)
+	}
+	writeJSON(w, http.StatusOK, owners)
+}
+
+func (s *Server) getOwner(w http.ResponseWriter, r *http.Request) {
+	entry, err := s.entry(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	owner, err := ownerOf(entry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, owner)
+}
+
+func (s *Server) createOwner(w http.ResponseWriter, r *http.Request) {
+	var owner vehicle.EngineOwner
+	if err := readJSON(r, &owner); err != nil {
+		writeError(w, err)
+		return
+	}
+	if owner.Name == "" {
+		writeError(w, badRequest("owner needs a name"))
+		return
+	}
+	if owner.UUID != "" && !vehicle.IsUUID(owner.UUID) {
+		// a number here would be stored as a UUID that GET /owners/{id} reads back as a numeric ID and never finds
+		writeError(w, badRequest(fmt.Sprintf("uuid %q is not a UUID such as 0b6c1b9e-7c5d-4f6e-9a3b-2d1e0f4c5b6a", owner.UUID)))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if owner.IsZero() {
+		if owner, err = s.registry.NewOwner(owner.Name, s.registry.NextSequentialID()); err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if _, ok := s.registry.Get(owner.OwnerID); ok {
+		writeError(w, fmt.Errorf("%w: ID %v is already registered", fleet.ErrOwnerConflict, owner.OwnerID))
+		return
+	} else if err = s.registry.AddOwner(owner); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.changed(); err != nil {
+		s.registry.RemoveOwner(owner.OwnerID) // what could not be saved is not kept either, s.mu keeps other changes out meanwhile
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, Owner{EngineOwner: owner, Engines: []Engine{}})
+}
+
+func (s *Server) listEngines(w http.ResponseWriter, r *http.Request) {
+	engines := []Engine{}
+	for _, entry := range s.registry.List() {
+		owner, err := ownerOf(entry)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		engines = append(engines, owner.Engines...)
+	}
+	writeJSON(w, http.StatusOK, engines)
+}
+
+func (s *Server) createEngine(w http.ResponseWriter, r *http.Request) {
+	var params vehicle.Params
+	if err := readJSON(r, &params); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, err := s.entry(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkEngineOwner(params, entry.Owner); err != nil {
+		writeError(w, err)
+		return
+	}
+	params["owner"] = entry.Owner // engines carry their owner, like the ones fleet add creates
+	e, err := vehicle.FromParams(params)
+	if err != nil {
+		writeError(w, badRequest(err.Error()))
+		return
+	}
+	index, err := s.registry.Add(entry.Owner, e)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.changed(); err != nil {
+		s.registry.Remove(entry.Owner.OwnerID, index)
+		writeError(w, err)
+		return
+	}
+	engine, err := engineOf(entry.Owner.OwnerID, index, e)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, engine)
+}
+
+func (s *Server) milesLeft(w http.ResponseWriter, r *http.Request) {
+	e, err := s.engine(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	left, err := e.MilesLeft()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MilesLeft{MilesLeft: left})
+}
+
+func (s *Server) canDrive(w http.ResponseWriter, r *http.Request) {
+	e, err := s.engine(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	miles, err := strconv.ParseFloat(r.URL.Query().Get("miles"), 64)
+	if err != nil || math.IsNaN(miles) || math.IsInf(miles, 0) {
+		writeError(w, badRequest("miles must be a finite number")) // ParseFloat accepts NaN and Inf
+		return
+	}
+	writeVerdict(w, e, miles)
+}
+
+func (s *Server) canDriveAdHoc(w http.ResponseWriter, r *http.Request) {
+	var req CanDriveRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := vehicle.FromParams(req.Engine)
+	if err != nil {
+		writeError(w, badRequest(err.Error()))
+		return
+	}
+	writeVerdict(w, e, req.Miles)
+}
+
+func writeVerdict(w http.ResponseWriter, e vehicle.Engine, miles float64) {
+	verdict, err := vehicle.CanDrive(e, miles)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, verdict)
+}
+
+// entry looks up the owner named by the {id} path segment
+func (s *Server) entry(r *http.Request) (fleet.Entry, error) {
+	id, err := vehicle.ParseOwnerID(r.PathValue("id"))
+	if err != nil {
+		return fleet.Entry{}, badRequest(err.Error())
+	}
+	entry, ok := s.registry.Get(id)
+	if !ok {
+		return fleet.Entry{}, fmt.Errorf("%w: ID %v", fleet.ErrNoOwner, id)
+	}
+	return entry, nil
+}
+
+// engine looks up the engine named by the {id} and {index} path segments
+func (s *Server) engine(r *http.Request) (vehicle.Engine, error) {
+	entry, err := s.entry(r)
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		return nil, badRequest("engine index must be a number")
+	}
+	if index < 0 || index >= len(entry.Engines) {
+		return nil, fmt.Errorf("%w: owner %v has %d engines, no index %d", fleet.ErrNoEngine, entry.Owner.OwnerID, len(entry.Engines), index)
+	}
+	return entry.Engines[index], nil
+}
+
+// checkEngineOwner rejects an "owner" in an engine's fields that is not the owner the engine is filed under
+// Leaving it out is the usual case, the owner from the path is filled in
+func checkEngineOwner(params vehicle.Params, owner vehicle.EngineOwner) error {
+	given, ok := params["owner"]
+	if !ok {
+		return nil
+	}
+	raw, err := json.Marshal(given)
+	if err != nil {
+		return badRequest("engine owner: " + err.Error())
+	}
+	var named vehicle.EngineOwner
+	if err := json.Unmarshal(raw, &named); err != nil {
+		return badRequest("engine owner: " + err.Error())
+	}
+	if named != owner {
+		return badRequest(fmt.Sprintf("engine names owner %v but is added to %v, leave out \"owner\" or make them match", named, owner))
+	}
+	return nil
+}
+
+// changed saves the registry, the handlers undo their change when it fails so memory and disk agree
+func (s *Server) changed() error {
+	if s.save == nil {
+		return nil
+	}
+	return s.save()
+}
+
+func ownerOf(entry fleet.Entry) (Owner, error) {
+	owner := Owner{EngineOwner: entry.Owner, Engines: make([]Engine, 0, len(entry.Engines))}
+	for i, e := range entry.Engines {
+		engine, err := engineOf(entry.Owner.OwnerID, i, e)
+		if err != nil {
+			return Owner{}, err
+		}
+		owner.Engines = append(owner.Engines, engine)
+	}
+	return owner, nil
+}
+
+func engineOf(owner vehicle.OwnerID, index int, e vehicle.Engine) (Engine, error) {
+	params, err := vehicle.ParamsOf(e)
+	if err != nil {
+		return Engine{}, err
+	}
+	engine := Engine{Owner: owner, Index: index, Engine: params}
+	if left, err := e.MilesLeft(); err != nil {
+		engine.Error = err.Error()
+	} else {
+		engine.MilesLeft = &left
+	}
+	return engine, nil
+}
+
+// requestError is a problem with the request itself, it is answered with 400 Bad Request
+type requestError struct{ msg string }
+
+func (e *requestError) Error() string { return e.msg }
+
+func badRequest(msg string) error { return &requestError{msg: msg} }
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+	dec.UseNumber() // keeps large owner IDs exact inside vehicle.Params
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid JSON body: " + err.Error())
+	}
+	return nil
+}
+
+// statusOf maps errors from the fleet and vehicle packages to HTTP status codes
+func statusOf(err error) int {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr),
+		errors.Is(err, vehicle.ErrNegativeTrip),
+		errors.Is(err, vehicle.ErrInvalidTrip),
+		errors.Is(err, vehicle.ErrUnknownKind),
+		errors.Is(err, fleet.ErrNoID):
+		return http.StatusBadRequest
+	case errors.Is(err, fleet.ErrNoOwner), errors.Is(err, fleet.ErrNoEngine):
+		return http.StatusNotFound
+	case errors.Is(err, fleet.ErrOwnerConflict), errors.Is(err, vehicle.ErrIDsExhausted):
+		return http.StatusConflict
+	case errors.Is(err, vehicle.ErrNegative), errors.Is(err, vehicle.ErrOverflow):
+		return http.StatusUnprocessableEntity // the engine is stored but its numbers give no range
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusOf(err), errorResponse{Error: err.Error()})
+}
+
+// writeJSON encodes v before writing the header, so a value that cannot be encoded is a 500 and not an empty 200
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(errorResponse{Error: "encoding response: " + err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
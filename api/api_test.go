@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// newTestServer serves a registry with one owner, ID 1, who has a gas engine with 100 miles left
+func newTestServer(t *testing.T, save func() error) *httptest.Server {
+	t.Helper()
+	registry := fleet.New()
+	owner := vehicle.EngineOwner{Name: "Donne", OwnerID: vehicle.OwnerID{ID: 1}}
+	if _, err := registry.Add(owner, vehicle.GasEngine{MPG: 25, Gallons: 4, TankGallons: 15, OwnerInfo: owner}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(New(registry, save))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// do sends one request and decodes a JSON response into out, out may be nil
+func do(t *testing.T, server *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: Content-Type %q", method, path, ct)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatalf("%s %s -> %d with a body that is not JSON: %v", method, path, resp.StatusCode, err)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, raw)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestStatusCodes(t *testing.T) {
+	server := newTestServer(t, nil)
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+	}{
+		{"list owners", "GET", "/owners", "", http.StatusOK},
+		{"owner by number", "GET", "/owners/1", "", http.StatusOK},
+		{"unknown owner", "GET", "/owners/2", "", http.StatusNotFound},
+		{"bad owner ID", "GET", "/owners/x", "", http.StatusBadRequest},
+		{"list engines", "GET", "/engines", "", http.StatusOK},
+		{"miles left", "GET", "/owners/1/engines/0/miles-left", "", http.StatusOK},
+		{"unknown engine", "GET", "/owners/1/engines/5/miles-left", "", http.StatusNotFound},
+		{"engine index", "GET", "/owners/1/engines/first/miles-left", "", http.StatusBadRequest},
+		{"can drive", "GET", "/owners/1/engines/0/can-drive?miles=50", "", http.StatusOK},
+		{"can drive no miles", "GET", "/owners/1/engines/0/can-drive", "", http.StatusBadRequest},
+		{"can drive negative", "GET", "/owners/1/engines/0/can-drive?miles=-1", "", http.StatusBadRequest},
+		{"can drive NaN", "GET", "/owners/1/engines/0/can-drive?miles=NaN", "", http.StatusBadRequest},
+		{"can drive Inf", "GET", "/owners/1/engines/0/can-drive?miles=Inf", "", http.StatusBadRequest},
+		{"can drive -Inf", "GET", "/owners/1/engines/0/can-drive?miles=-Inf", "", http.StatusBadRequest},
+		{"ad hoc", "POST", "/can-drive", `{"engine":{"type":"electric","mpkwh":3,"kwh":10},"miles":50}`, http.StatusOK},
+		{"ad hoc unknown kind", "POST", "/can-drive", `{"engine":{"type":"steam"},"miles":50}`, http.StatusBadRequest},
+		{"ad hoc bad JSON", "POST", "/can-drive", `{"engine":`, http.StatusBadRequest},
+		{"owner without name", "POST", "/owners", `{"id":9}`, http.StatusBadRequest},
+		{"owner ID conflict", "POST", "/owners", `{"name":"E","id":1}`, http.StatusConflict},
+		{"owner numeric UUID", "POST", "/owners", `{"name":"E","uuid":"1"}`, http.StatusBadRequest},
+		{"owner upper case UUID", "POST", "/owners", `{"name":"E","uuid":"0B6C1B9E-7C5D-4F6E-9A3B-2D1E0F4C5B6A"}`, http.StatusBadRequest},
+		{"engine unknown field", "POST", "/owners/1/engines", `{"type":"gas","mpg":25,"galons":4}`, http.StatusBadRequest},
+		{"hybrid unknown field", "POST", "/owners/1/engines", `{"type":"hybrid","mpgg":5}`, http.StatusBadRequest},
+		{"engine for unknown owner", "POST", "/owners/2/engines", `{"type":"gas","mpg":25,"gallons":4}`, http.StatusNotFound},
+		{"engine names another owner", "POST", "/owners/1/engines", `{"type":"gas","mpg":25,"gallons":4,"owner":{"name":"Eve","id":2}}`, http.StatusBadRequest},
+		{"engine renames its owner", "POST", "/owners/1/engines", `{"type":"gas","mpg":25,"gallons":4,"owner":{"name":"Eve","id":1}}`, http.StatusBadRequest},
+		{"engine names its owner", "POST", "/owners/1/engines", `{"type":"gas","mpg":25,"gallons":4,"owner":{"name":"Donne","id":1}}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := do(t, server, tt.method, tt.path, tt.body, nil); status != tt.status {
+				t.Errorf("%s %s -> %d, want %d", tt.method, tt.path, status, tt.status)
+			}
+		})
+	}
+}
+
+func TestCreateAndRead(t *testing.T) {
+	saves := 0
+	server := newTestServer(t, func() error { saves++; return nil })
+
+	const uuid = "0b6c1b9e-7c5d-4f6e-9a3b-2d1e0f4c5b6a"
+	var created Owner
+	if status := do(t, server, "POST", "/owners", `{"name":"Eve","uuid":"`+uuid+`"}`, &created); status != http.StatusCreated {
+		t.Fatalf("create owner -> %d", status)
+	}
+	if created.UUID != uuid || created.Name != "Eve" {
+		t.Errorf("created %+v", created)
+	}
+
+	var engine Engine
+	if status := do(t, server, "POST", "/owners/"+uuid+"/engines", `{"type":"electric","mpkwh":3,"kwh":10}`, &engine); status != http.StatusCreated {
+		t.Fatalf("create engine -> %d", status)
+	}
+	if engine.Index != 0 || engine.MilesLeft == nil || *engine.MilesLeft != 30 {
+		t.Errorf("created engine %+v", engine)
+	}
+
+	// Every owner created over the API can be read back by the ID it was created with
+	var owner Owner
+	if status := do(t, server, "GET", "/owners/"+uuid, "", &owner); status != http.StatusOK {
+		t.Fatalf("get owner -> %d", status)
+	}
+	if len(owner.Engines) != 1 || owner.Engines[0].Engine["type"] != "electric" {
+		t.Errorf("owner %+v", owner)
+	}
+
+	var next Owner
+	if status := do(t, server, "POST", "/owners", `{"name":"Fay"}`, &next); status != http.StatusCreated {
+		t.Fatalf("create owner without ID -> %d", status)
+	}
+	if next.ID != 2 {
+		t.Errorf("owner without ID got ID %v, want 2", next.OwnerID)
+	}
+
+	// An engine whose numbers give no range is stored, only its range is an error
+	var negative Engine
+	if status := do(t, server, "POST", "/owners/1/engines", `{"type":"gas","mpg":25,"gallons":-4}`, &negative); status != http.StatusCreated {
+		t.Fatalf("create negative engine -> %d", status)
+	}
+	if negative.MilesLeft != nil || negative.Error == "" {
+		t.Errorf("negative engine %+v", negative)
+	}
+	if status := do(t, server, "GET", "/owners/1/engines/1/miles-left", "", nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("miles left of a negative engine -> %d, want 422", status)
+	}
+
+	var verdict vehicle.Verdict
+	if status := do(t, server, "GET", "/owners/1/engines/0/can-drive?miles=120", "", &verdict); status != http.StatusOK {
+		t.Fatalf("can drive -> %d", status)
+	}
+	if verdict.Feasible || verdict.Range != 100 || verdict.Shortfall != 20 {
+		t.Errorf("verdict %+v", verdict)
+	}
+	if saves != 4 {
+		t.Errorf("save called %d times for 4 changes", saves)
+	}
+}
+
+// TestSaveError checks that a change that cannot be saved is answered with 500 and not kept in memory either
+func TestSaveError(t *testing.T) {
+	server := newTestServer(t, func() error { return errors.New("disk full") })
+	var resp errorResponse
+	if status := do(t, server, "POST", "/owners", `{"name":"Eve","id":2}`, &resp); status != http.StatusInternalServerError {
+		t.Errorf("failed save -> %d, want 500", status)
+	}
+	if resp.Error != "disk full" {
+		t.Errorf("error %q", resp.Error)
+	}
+	if status := do(t, server, "GET", "/owners/2", "", nil); status != http.StatusNotFound {
+		t.Errorf("owner whose save failed -> %d, want 404", status)
+	}
+
+	if status := do(t, server, "POST", "/owners/1/engines", `{"type":"electric","mpkwh":3,"kwh":10}`, nil); status != http.StatusInternalServerError {
+		t.Errorf("failed save -> %d, want 500", status)
+	}
+	var owner Owner
+	do(t, server, "GET", "/owners/1", "", &owner)
+	if len(owner.Engines) != 1 {
+		t.Errorf("owner has %d engines after a failed save, want the 1 it started with", len(owner.Engines))
+	}
+}
+
+func TestWriteJSONEncodeError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, http.StatusOK, MilesLeft{MilesLeft: math.Inf(1)}) // encoding/json has no Inf
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", rec.Code)
+	}
+	var resp errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+		t.Errorf("body %q is not an error response: %v", rec.Body, err)
+	}
+}
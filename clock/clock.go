@@ -0,0 +1,216 @@
+// Package clock lets code that sleeps or measures time run against real time or a virtual clock
+package clock
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the subset of the time package the demos use
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	AfterFunc(d time.Duration, f func()) (stop func() bool) // runs f in its own go routine after d, stop cancels it and reports whether it did
+}
+
+// Real is the wall clock, it simply calls the time package
+type Real struct{}
+
+func (Real) Now() time.Time                  { return time.Now() }
+func (Real) Since(t time.Time) time.Duration { return time.Since(t) }
+func (Real) Sleep(d time.Duration)           { time.Sleep(d) }
+func (Real) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// SleepContext sleeps for d on c unless ctx is done first, it then returns context.Cause(ctx)
+func SleepContext(ctx context.Context, c Clock, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return context.Cause(ctx)
+	}
+	done := make(chan struct{})
+	stop := c.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		stop()
+		return context.Cause(ctx)
+	}
+}
+
+// WithTimeout is context.WithTimeout measured on c, so a timeout on a Fake clock passes in virtual time
+// Both clocks use the same timer, so they report a timeout the same way, which differs from context.WithTimeout:
+// once d has passed ctx.Err() is context.Canceled and context.Cause(ctx) is context.DeadlineExceeded,
+// and ctx.Deadline() reports no deadline. Check for a timeout with context.Cause
+func WithTimeout(ctx context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := c.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// settle is how long an auto-advancing Fake waits, in real time, for sleeping go routines to stop changing
+// before it jumps to the next deadline
+const settle = 2 * time.Millisecond
+
+// Fake is a virtual clock, time only moves when Advance is called or, in auto mode, when every sleeper is waiting
+// Sleep blocks until virtual time reaches the sleeper's deadline, so concurrent sleeps overlap the way real ones do:
+// five go routines sleeping up to 2s each finish after 2s of virtual time, not 10s
+type Fake struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []*sleeper
+	auto     bool
+	running  bool // an auto-advance loop is active
+	changes  int  // bumped whenever sleepers change, so the auto-advance loop can tell things have settled
+}
+
+// sleeper is a pending Sleep or AfterFunc, fire is called with f.mu held once the deadline has passed
+type sleeper struct {
+	deadline time.Time
+	fire     func()
+}
+
+// NewFake returns a virtual clock starting at start that only moves when Advance is called
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// NewAuto returns a virtual clock starting at start that advances by itself
+// Once the sleeping go routines have settled it jumps straight to the earliest deadline, so sleeps take no real time
+func NewAuto(start time.Time) *Fake {
+	return &Fake{now: start, auto: true}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep blocks until virtual time has moved forward by d
+func (f *Fake) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	wake := make(chan struct{})
+	f.mu.Lock()
+	f.add(d, func() { close(wake) })
+	f.mu.Unlock()
+	<-wake
+}
+
+// AfterFunc runs fn in its own go routine once virtual time has moved forward by d
+// The stop function removes it, so a stopped timer no longer counts as a sleeper
+func (f *Fake) AfterFunc(d time.Duration, fn func()) func() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d <= 0 {
+		go fn()
+		return func() bool { return false }
+	}
+	s := f.add(d, func() { go fn() })
+	return func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		i := slices.Index(f.sleepers, s)
+		if i < 0 {
+			return false // already fired or stopped
+		}
+		f.sleepers = slices.Delete(f.sleepers, i, i+1)
+		f.changes++
+		return true
+	}
+}
+
+// add must be called with f.mu held
+func (f *Fake) add(d time.Duration, fire func()) *sleeper {
+	s := &sleeper{deadline: f.now.Add(d), fire: fire}
+	f.sleepers = append(f.sleepers, s)
+	f.changes++
+	if f.auto && !f.running {
+		f.running = true
+		go f.autoAdvance()
+	}
+	return s
+}
+
+// Advance moves virtual time forward by d and wakes every sleeper whose deadline has passed
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advanceTo(f.now.Add(d))
+}
+
+// Sleepers returns how many go routines are currently blocked in Sleep, pending AfterFunc timers count as well
+func (f *Fake) Sleepers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sleepers)
+}
+
+// BlockUntil waits, in real time, until at least n go routines are blocked in Sleep
+// Tests use it before Advance to be sure the go routines they started have reached their sleep
+func (f *Fake) BlockUntil(n int) {
+	for f.Sleepers() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// advanceTo must be called with f.mu held
+func (f *Fake) advanceTo(t time.Time) {
+	if t.After(f.now) {
+		f.now = t
+	}
+	sort.SliceStable(f.sleepers, func(i, j int) bool { return f.sleepers[i].deadline.Before(f.sleepers[j].deadline) })
+	woken := 0
+	for _, s := range f.sleepers {
+		if s.deadline.After(f.now) {
+			break
+		}
+		s.fire()
+		woken++
+	}
+	if woken > 0 {
+		f.sleepers = f.sleepers[woken:]
+		f.changes++
+	}
+}
+
+func (f *Fake) autoAdvance() {
+	for {
+		f.mu.Lock()
+		if len(f.sleepers) == 0 {
+			f.running = false
+			f.mu.Unlock()
+			return
+		}
+		changes := f.changes
+		f.mu.Unlock()
+
+		time.Sleep(settle) // give go routines that are about to sleep a chance to register first
+
+		f.mu.Lock()
+		if f.changes == changes && len(f.sleepers) > 0 {
+			earliest := f.sleepers[0].deadline
+			for _, s := range f.sleepers[1:] {
+				if s.deadline.Before(earliest) {
+					earliest = s.deadline
+				}
+			}
+			f.advanceTo(earliest)
+		}
+		f.mu.Unlock()
+	}
+}
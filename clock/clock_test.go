@@ -0,0 +1,159 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeAdvance(t *testing.T) {
+	f := NewFake(epoch)
+	woke := make(chan time.Time)
+	go func() {
+		f.Sleep(time.Second)
+		woke <- f.Now()
+	}()
+	f.BlockUntil(1)
+
+	f.Advance(999 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatal("Sleep(1s) returned after 999ms")
+	case <-time.After(10 * time.Millisecond): // real time, the sleeper must stay blocked
+	}
+	if n := f.Sleepers(); n != 1 {
+		t.Errorf("Sleepers() = %d, want 1", n)
+	}
+
+	f.Advance(time.Millisecond)
+	if got := <-woke; !got.Equal(epoch.Add(time.Second)) {
+		t.Errorf("woke at %v, want %v", got, epoch.Add(time.Second))
+	}
+	if n := f.Sleepers(); n != 0 {
+		t.Errorf("Sleepers() = %d after waking, want 0", n)
+	}
+	if d := f.Since(epoch); d != time.Second {
+		t.Errorf("Since(start) = %v, want 1s", d)
+	}
+}
+
+// TestFakeOverlappingSleeps checks that concurrent sleeps overlap: one Advance past every deadline wakes them all
+func TestFakeOverlappingSleeps(t *testing.T) {
+	f := NewFake(epoch)
+	var wg sync.WaitGroup
+	for _, d := range []time.Duration{300 * time.Millisecond, time.Second, 2 * time.Second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Sleep(d)
+		}()
+	}
+	f.BlockUntil(3)
+	f.Advance(time.Second)
+	if n := f.Sleepers(); n != 1 {
+		t.Errorf("Sleepers() = %d after 1s, want only the 2s sleeper", n)
+	}
+	f.Advance(time.Second)
+	wg.Wait()
+	if d := f.Since(epoch); d != 2*time.Second {
+		t.Errorf("three overlapping sleeps took %v of virtual time, want 2s", d)
+	}
+}
+
+func TestAutoOverlappingSleeps(t *testing.T) {
+	f := NewAuto(epoch)
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Sleep(time.Duration(i) * 400 * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	if d := f.Since(epoch); d != 2*time.Second {
+		t.Errorf("sleeps of up to 2s took %v of virtual time, want 2s", d)
+	}
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	f := NewFake(epoch)
+	fired := make(chan struct{})
+	f.AfterFunc(time.Second, func() { close(fired) })
+	stop := f.AfterFunc(time.Second, func() { t.Error("stopped timer fired") })
+	if !stop() {
+		t.Error("stop() = false for a pending timer")
+	}
+	if stop() {
+		t.Error("stop() = true for a timer that was already stopped")
+	}
+	f.Advance(time.Second)
+	<-fired
+	if n := f.Sleepers(); n != 0 {
+		t.Errorf("Sleepers() = %d, want 0", n)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	f := NewFake(epoch)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	errc := make(chan error)
+	go func() { errc <- SleepContext(ctx, f, time.Hour) }()
+	f.BlockUntil(1)
+	stopped := errors.New("stopped")
+	cancel(stopped)
+	if err := <-errc; !errors.Is(err, stopped) {
+		t.Errorf("SleepContext = %v, want the context's cause", err)
+	}
+	if n := f.Sleepers(); n != 0 {
+		t.Errorf("the cancelled sleep left %d timers behind", n)
+	}
+	if err := SleepContext(ctx, f, time.Hour); !errors.Is(err, stopped) {
+		t.Errorf("SleepContext on a done context = %v, want the cause straight away", err)
+	}
+}
+
+func TestWithTimeoutFake(t *testing.T) {
+	f := NewFake(epoch)
+	ctx, cancel := WithTimeout(context.Background(), f, time.Second)
+	defer cancel()
+
+	f.Advance(999 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("done before its timeout: %v", err)
+	}
+	f.Advance(time.Millisecond)
+	<-ctx.Done() // the timer cancels from its own go routine
+	checkTimedOut(t, ctx)
+}
+
+func TestWithTimeoutReal(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), Real{}, time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	checkTimedOut(t, ctx)
+}
+
+// checkTimedOut checks the documented result of a timeout, the same on every clock
+func checkTimedOut(t *testing.T, ctx context.Context) {
+	t.Helper()
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx.Err() = %v, want context.Canceled", err)
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, context.DeadlineExceeded) {
+		t.Errorf("context.Cause(ctx) = %v, want context.DeadlineExceeded", cause)
+	}
+}
+
+func TestWithTimeoutCancel(t *testing.T) {
+	f := NewFake(epoch)
+	_, cancel := WithTimeout(context.Background(), f, time.Second)
+	cancel()
+	if n := f.Sleepers(); n != 0 {
+		t.Errorf("cancel left %d timers behind", n)
+	}
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/api"
+	"github.com/donnebaldemeca/GoBasics/cost"
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/fleetrpc"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+/*
+
+	Fleet commands
+
+	go run ./cmd/main fleet add --owner Donne --owner-id 1 --type gas --mpg 25 --gallons 15
+	go run ./cmd/main fleet add --owner Alice --id-scheme uuid --type electric --mpkwh 3 --kwh 10
+	go run ./cmd/main fleet add --owner Alice --type pedal --set mph=12 --set hours=2.5
+	go run ./cmd/main fleet update --owner-id 1 --index 0 --gallons 10
+	go run ./cmd/main fleet remove --owner-id 1 --index 0
+	go run ./cmd/main fleet list
+	go run ./cmd/main fleet list --sort range --desc --type electric --min-range 20
+	go run ./cmd/main fleet cost --miles 100 --at 23:00 --prices prices.json
+	go run ./cmd/main fleet cost --miles 100 --factors factors.json
+	go run ./cmd/main fleet stats --units metric
+	go run ./cmd/main fleet export --to shared.csv
+	go run ./cmd/main fleet import --from shared.yaml
+	go run ./cmd/main fleet serve --addr localhost:8080
+	go run ./cmd/main fleet serve-rpc --network unix --addr /tmp/fleet.sock
+	go run ./cmd/main fleet call --network unix --addr /tmp/fleet.sock --method can-drive --owner-id 1 --index 0 --miles 120
+
+	The fleet is kept in fleet.json, --file picks another file and its extension the format: .json, .csv, .yaml or .gob
+
+*/
+
+const defaultFleetFile = "fleet.json"
+
+// engineFlags maps command line flags to the JSON field names of the engine types
+var engineFlags = []struct{ flag, field, usage string }{
+	{"mpg", "mpg", "miles per gallon (gas, hybrid)"},
+	{"gallons", "gallons", "gallons in the tank (gas, hybrid)"},
+	{"tank-gallons", "tank_gallons", "tank size in gallons (gas, hybrid)"},
+	{"mpkwh", "mpkwh", "miles per kWh (electric, hybrid)"},
+	{"kwh", "kwh", "kWh in the battery (electric, hybrid)"},
+	{"battery-kwh", "battery_kwh", "battery size in kWh (electric, hybrid)"},
+	{"miles-per-kg", "miles_per_kg", "miles per kg of hydrogen (hydrogen)"},
+	{"kg", "kg", "kg of hydrogen in the tank (hydrogen)"},
+	{"tank-kg", "tank_kg", "tank size in kg (hydrogen)"},
+	{"order", "order", "drain order: electric-first, gas-first or blended (hybrid)"},
+}
+
+// fleetCommand holds the flags shared by every fleet subcommand
+type fleetCommand struct {
+	flags    *flag.FlagSet
+	file     *string
+	owner    *string
+	ownerID  *string
+	idScheme *string
+	index    *int
+	kind     *string
+	fields   map[string]*string
+	set      paramList
+
+	// fleet list
+	sort     *string
+	desc     *bool
+	minRange *float64
+
+	// fleet cost
+	miles   *float64
+	prices  *string
+	factors *string
+	at      *string
+	units   *string
+
+	// fleet import and export
+	from   *string
+	to     *string
+	format *string
+
+	// fleet serve, serve-rpc and call
+	addr    *string
+	network *string
+	method  *string
+	source  *string
+	amount  *float64
+}
+
+func newFleetCommand(name string) *fleetCommand {
+	c := &fleetCommand{flags: flag.NewFlagSet("fleet "+name, flag.ContinueOnError), fields: make(map[string]*string)}
+	c.file = c.flags.String("file", defaultFleetFile, "fleet file, .json, .csv, .yaml or .gob, the extension picks the format")
+	c.owner = c.flags.String("owner", "", "owner name")
+	c.ownerID = c.flags.String("owner-id", "", "owner ID, a number or a UUID")
+	c.idScheme = c.flags.String("id-scheme", "seq", "how fleet add picks an ID when --owner-id is not given: seq or uuid")
+	c.index = c.flags.Int("index", -1, "engine index within the owner, as shown by fleet list")
+	c.kind = c.flags.String("type", "", "engine type, one of "+strings.Join(vehicle.Kinds(), ", "))
+	for _, f := range engineFlags {
+		c.fields[f.field] = c.flags.String(f.flag, "", f.usage)
+	}
+	c.flags.Var(&c.set, "set", "any engine field as name=value, may be repeated, for types without their own flags")
+	c.sort = c.flags.String("sort", "", "fleet list order: range, efficiency, owner or id")
+	c.desc = c.flags.Bool("desc", false, "fleet list in descending order")
+	c.minRange = c.flags.Float64("min-range", 0, "fleet list only engines with at least this range, in miles or with --units metric in km")
+	c.miles = c.flags.Float64("miles", 100, "trip length for fleet cost")
+	c.prices = c.flags.String("prices", "", "JSON price table for fleet cost, built in US prices when empty")
+	c.factors = c.flags.String("factors", "", "JSON emission factors for fleet cost in kg CO2 per unit, e.g. {\"electricity\": 0.2}; missing sources keep the built in ones")
+	c.at = c.flags.String("at", "12:00", "time of day the trip's energy is bought, HH:MM")
+	c.units = c.flags.String("units", "imperial", "imperial or metric")
+	c.from = c.flags.String("from", "", "file fleet import reads")
+	c.to = c.flags.String("to", "-", "file fleet export writes, - for standard output")
+	c.format = c.flags.String("format", "", "json, csv, yaml or gob, picked from the file extension when empty")
+	c.addr = c.flags.String("addr", "", "address to listen on or call, localhost:8080 for serve and localhost:8090 for serve-rpc and call")
+	c.network = c.flags.String("network", "tcp", "tcp or unix, for serve-rpc and call")
+	c.method = c.flags.String("method", "", "fleet call method: miles-left, can-drive, refuel or list")
+	c.source = c.flags.String("source", "", "energy source fleet call --method refuel adds: gasoline, electricity or hydrogen")
+	c.amount = c.flags.Float64("amount", 0, "amount fleet call --method refuel adds, 0 fills up")
+	return c
+}
+
+// params returns the engine fields given on the command line, keyed by their JSON names
+func (c *fleetCommand) params() (vehicle.Params, error) {
+	set := make(map[string]bool)
+	c.flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	params := make(vehicle.Params)
+	for _, f := range engineFlags {
+		if !set[f.flag] {
+			continue
+		}
+		value := *c.fields[f.field]
+		if f.field == "order" {
+			params[f.field] = value
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %q is not a number", f.flag, value)
+		}
+		params[f.field] = number
+	}
+	for field, value := range c.set {
+		params[field] = value
+	}
+	return params, nil
+}
+
+// paramList is a flag.Value collecting name=value pairs, values that parse as numbers are stored as numbers
+type paramList map[string]any
+
+func (p *paramList) String() string {
+	return fmt.Sprint(map[string]any(*p))
+}
+
+func (p *paramList) Set(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not name=value", value)
+	}
+	if *p == nil {
+		*p = make(paramList)
+	}
+	if number, err := strconv.ParseFloat(raw, 64); err == nil {
+		(*p)[name] = number
+	} else {
+		(*p)[name] = raw
+	}
+	return nil
+}
+
+func (c *fleetCommand) id() (vehicle.OwnerID, error) {
+	if *c.ownerID == "" {
+		return vehicle.OwnerID{}, errors.New("--owner-id is required")
+	}
+	return vehicle.ParseOwnerID(*c.ownerID)
+}
+
+// allocator returns the ID allocator picked with --id-scheme
+func (c *fleetCommand) allocator(registry *fleet.Registry) (vehicle.IDAllocator, error) {
+	switch *c.idScheme {
+	case "seq":
+		return registry.NextSequentialID(), nil
+	case "uuid":
+		return vehicle.RandomIDs{}, nil
+	}
+	return nil, fmt.Errorf("unknown --id-scheme %q (want seq or uuid)", *c.idScheme)
+}
+
+// runFleet runs a fleet subcommand and returns the process exit code
+func runFleet(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: fleet add|update|remove|list|cost|stats|import|export|serve|serve-rpc|call [flags]")
+		return 2
+	}
+	c := newFleetCommand(args[0])
+	c.flags.SetOutput(stderr)
+	if err := c.flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if args[0] == "call" { // a client, the fleet file belongs to the server
+		if err := fleetCall(c, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+	registry, err := fleet.Load(*c.file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "add":
+		err = fleetAdd(c, registry, stdout)
+	case "update":
+		err = fleetUpdate(c, registry, stdout)
+	case "remove":
+		err = fleetRemove(c, registry, stdout)
+	case "list":
+		if err := fleetList(c, registry, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "cost":
+		if err := fleetCost(c, registry, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "stats":
+		if err := fleetStats(c, registry, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "export":
+		if err := fleetExport(c, registry, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "import":
+		err = fleetImport(c, registry, stdout)
+	case "serve":
+		if err := fleetServe(c, registry, stderr); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "serve-rpc":
+		if err := fleetServeRPC(c, registry, stderr); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown fleet command %q\n", args[0])
+		return 2
+	}
+	if err == nil {
+		err = registry.Save(*c.file)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func fleetAdd(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	if *c.kind == "" || *c.owner == "" {
+		return errors.New("fleet add needs --type and --owner")
+	}
+	params, err := c.params()
+	if err != nil {
+		return err
+	}
+
+	// A new owner without --owner-id gets the next free ID from the chosen allocator
+	owner := vehicle.EngineOwner{Name: *c.owner}
+	if *c.ownerID != "" {
+		if owner.OwnerID, err = c.id(); err != nil {
+			return err
+		}
+	} else {
+		alloc, err := c.allocator(registry)
+		if err != nil {
+			return err
+		}
+		if owner, err = registry.NewOwner(*c.owner, alloc); err != nil {
+			return err
+		}
+	}
+
+	params["type"] = *c.kind
+	params["owner"] = owner
+	e, err := vehicle.FromParams(params)
+	if err != nil {
+		return err
+	}
+	index, err := registry.Add(owner, e)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "added %s engine %d for %v\n", *c.kind, index, owner)
+	return nil
+}
+
+// fleetUpdate only changes the fields given on the command line, the rest of the engine is kept
+func fleetUpdate(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	id, err := c.id()
+	if err != nil {
+		return err
+	}
+	entry, ok := registry.Get(id)
+	if !ok {
+		return fmt.Errorf("%w: ID %v", fleet.ErrNoOwner, id)
+	}
+	if *c.index < 0 || *c.index >= len(entry.Engines) {
+		return fmt.Errorf("%w: owner %v has %d engines, no index %d", fleet.ErrNoEngine, id, len(entry.Engines), *c.index)
+	}
+
+	params, err := vehicle.ParamsOf(entry.Engines[*c.index])
+	if err != nil {
+		return err
+	}
+	changes, err := c.params()
+	if err != nil {
+		return err
+	}
+	for field, value := range changes {
+		params[field] = value
+	}
+	if *c.kind != "" {
+		params["type"] = *c.kind
+	}
+	e, err := vehicle.FromParams(params)
+	if err != nil {
+		return err
+	}
+	if err := registry.Update(id, *c.index, e); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "updated engine %d for owner %v\n", *c.index, id)
+	return nil
+}
+
+// fleetRemove removes one engine with --index, or the owner and all their engines without it
+func fleetRemove(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	id, err := c.id()
+	if err != nil {
+		return err
+	}
+	if *c.index < 0 {
+		if err := registry.RemoveOwner(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "removed owner %v\n", id)
+		return nil
+	}
+	if err := registry.Remove(id, *c.index); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "removed engine %d for owner %v\n", *c.index, id)
+	return nil
+}
+
+// fleetList prints engines grouped by owner, or with --sort, --desc, --type or --min-range one engine per line
+func fleetList(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	sortKey, err := fleet.ParseSortKey(*c.sort)
+	if err != nil {
+		return err
+	}
+	units, err := vehicle.ParseUnitSystem(*c.units)
+	if err != nil {
+		return err
+	}
+	q := fleet.Query{Sort: sortKey, Descending: *c.desc, Kind: *c.kind, MinRange: *c.minRange}
+	if units == vehicle.Metric {
+		q.MinRange = vehicle.KmToMiles(q.MinRange)
+	}
+
+	if q == (fleet.Query{}) {
+		for _, entry := range registry.List() {
+			fmt.Fprintln(w, entry.Owner)
+			for i, e := range entry.Engines {
+				fmt.Fprintf(w, "  [%d] %s\n", i, describeEngine(e, units))
+			}
+		}
+		return nil
+	}
+	items, err := registry.Select(q)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		mpge := ""
+		if !math.IsNaN(item.MPGe) {
+			mpge = fmt.Sprintf(", %.1f MPGe", item.MPGe)
+		}
+		fmt.Fprintf(w, "%v [%d] %s%s\n", item.Owner, item.Index, describeEngine(item.Engine, units), mpge)
+	}
+	return nil
+}
+
+// fleetCost prints a cost and emissions table for the same trip across every engine in the fleet
+func fleetCost(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	prices := cost.DefaultPrices
+	if *c.prices != "" {
+		data, err := os.ReadFile(*c.prices)
+		if err != nil {
+			return err
+		}
+		prices = cost.Prices{}
+		if err := json.Unmarshal(data, &prices); err != nil {
+			return fmt.Errorf("%s: %w", *c.prices, err)
+		}
+	}
+	factors := cost.DefaultFactors
+	if *c.factors != "" {
+		data, err := os.ReadFile(*c.factors)
+		if err != nil {
+			return err
+		}
+		if factors, err = cost.ParseFactors(data); err != nil {
+			return fmt.Errorf("%s: %w", *c.factors, err)
+		}
+	}
+	at, err := time.Parse("15:04", *c.at)
+	if err != nil {
+		return fmt.Errorf("--at: %w", err)
+	}
+	if err := vehicle.CheckTrip(*c.miles); err != nil { // one message instead of the same error on every row
+		return fmt.Errorf("--miles: %w", err)
+	}
+	units, err := vehicle.ParseUnitSystem(*c.units)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	var engines []vehicle.Engine
+	for _, entry := range registry.List() {
+		for i, e := range entry.Engines {
+			names = append(names, fmt.Sprintf("%s [%d]", entry.Owner.Name, i))
+			engines = append(engines, e)
+		}
+	}
+	return cost.WriteTable(w, cost.Compare(names, engines, *c.miles, prices, factors, at), units)
+}
+
+// fleetStats prints range statistics for the whole fleet, per engine type and per owner
+func fleetStats(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	units, err := vehicle.ParseUnitSystem(*c.units)
+	if err != nil {
+		return err
+	}
+	report, err := registry.Stats()
+	if err != nil {
+		return err
+	}
+	return fleet.WriteReport(w, report, units)
+}
+
+// fileFormat returns the format named by --format, or the one path's extension implies
+// Standard output, path "-", is JSON unless --format says otherwise
+func (c *fleetCommand) fileFormat(path string) (fleet.Format, error) {
+	if *c.format != "" {
+		return fleet.ParseFormat(*c.format)
+	}
+	return fleet.FormatOf(path), nil
+}
+
+// fleetImport adds the owners and engines of another fleet file to this one
+func fleetImport(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	if *c.from == "" {
+		return errors.New("fleet import needs --from")
+	}
+	format, err := c.fileFormat(*c.from)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(*c.from)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	imported, err := fleet.Import(f, format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *c.from, err)
+	}
+	if err := registry.Merge(imported); err != nil {
+		return err
+	}
+	var engines int
+	entries := imported.List()
+	for _, entry := range entries {
+		engines += len(entry.Engines)
+	}
+	fmt.Fprintf(w, "imported %d owners and %d engines from %s\n", len(entries), engines, *c.from)
+	return nil
+}
+
+// fleetExport writes the fleet in another format, to a file or standard output
+func fleetExport(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	format, err := c.fileFormat(*c.to)
+	if err != nil {
+		return err
+	}
+	if *c.to == "-" {
+		return registry.Export(w, format)
+	}
+	f, err := os.Create(*c.to)
+	if err != nil {
+		return err
+	}
+	if err := registry.Export(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fleetServe serves the fleet over HTTP until the process is stopped, every change is saved to --file
+func fleetServe(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	server := &http.Server{
+		Addr:              c.address("localhost:8080"),
+		Handler:           api.New(registry, func() error { return registry.Save(*c.file) }),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(w, "serving %s on http://%s\n", *c.file, server.Addr)
+	return server.ListenAndServe()
+}
+
+// address returns --addr, or def when it was not given
+func (c *fleetCommand) address(def string) string {
+	if *c.addr == "" {
+		return def
+	}
+	return *c.addr
+}
+
+// fleetServeRPC answers JSON-RPC calls until interrupted, closing the listener also removes a unix socket file
+func fleetServeRPC(c *fleetCommand, registry *fleet.Registry, w io.Writer) error {
+	l, err := net.Listen(*c.network, c.address("localhost:8090"))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	fmt.Fprintf(w, "serving %s over JSON-RPC on %s %s\n", *c.file, l.Addr().Network(), l.Addr())
+	return fleetrpc.Serve(l, fleetrpc.NewService(registry, func() error { return registry.Save(*c.file) }))
+}
+
+// fleetCall calls one method of a fleet serve-rpc server and prints the reply as JSON
+func fleetCall(c *fleetCommand, w io.Writer) error {
+	client, err := fleetrpc.Dial(*c.network, c.address("localhost:8090"))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ref := fleetrpc.EngineRef{Owner: *c.ownerID, Index: max(*c.index, 0)}
+	var reply any
+	switch *c.method {
+	case "miles-left":
+		reply, err = client.MilesLeft(ref)
+	case "can-drive":
+		reply, err = client.CanDrive(ref, *c.miles)
+	case "refuel":
+		reply, err = client.Refuel(ref, vehicle.Source(*c.source), *c.amount)
+	case "list":
+		reply, err = client.List(*c.ownerID)
+	default:
+		return fmt.Errorf("unknown --method %q (want miles-left, can-drive, refuel or list)", *c.method)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reply)
+}
+
+// describeEngine prints an engine's type, range in units and fields on one line
+func describeEngine(e vehicle.Engine, units vehicle.UnitSystem) string {
+	kind, err := vehicle.KindOf(e)
+	if err != nil {
+		kind = fmt.Sprintf("%T", e)
+	}
+	var sb strings.Builder
+	sb.WriteString(kind)
+	if left, err := e.MilesLeft(); err != nil {
+		fmt.Fprintf(&sb, ", range error: %v", err)
+	} else {
+		fmt.Fprintf(&sb, ", %s left", vehicle.FormatDistance(left, units))
+	}
+	if fields, err := vehicle.ParamsOf(e); err == nil {
+		for _, f := range engineFlags {
+			if v, ok := fields[f.field]; ok {
+				fmt.Fprintf(&sb, ", %s %v", f.flag, v)
+				delete(fields, f.field)
+			}
+		}
+		// fields of engine types registered elsewhere have no flag, they are printed under their JSON names
+		delete(fields, "type")
+		delete(fields, "owner")
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			fmt.Fprintf(&sb, ", %s %v", name, fields[name])
+		}
+	}
+	return sb.String()
+}
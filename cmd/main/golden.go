@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+)
+
+/*
+
+	Golden files
+
+	Every section's text output is checked in under testdata/golden/<section>.golden
+	go test ./cmd/main runs every section in deterministic mode and compares its output to those files,
+	go test ./cmd/main -update rewrites the files instead, after a lesson is changed on purpose
+	--golden and --update-golden do the same from the command line, for a quick check of a few sections
+
+	Some output can never repeat between runs, so it is normalized before comparing:
+	memory addresses, measured durations, and the line order of sections that print from go routines
+
+*/
+
+const goldenDir = "cmd/main/testdata/golden"
+
+var (
+	addressPattern  = regexp.MustCompile(`0x[0-9a-f]+`)
+	durationPattern = regexp.MustCompile(`\b(\d+(\.\d+)?(h|m|s|ms|µs|us|ns))+\b`)
+)
+
+func normalize(sec section, output string) string {
+	output = addressPattern.ReplaceAllString(output, "0xADDR")
+	output = durationPattern.ReplaceAllString(output, "DURATION")
+	if sec.concurrent {
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		slices.Sort(lines)
+		output = strings.Join(lines, "\n") + "\n"
+	}
+	return output
+}
+
+// renderGolden runs sec in deterministic mode on a virtual clock and returns its normalized text output
+// Both --golden and the tests in golden_test.go compare this against the section's golden file
+func renderGolden(sec section, seed int64) (string, error) {
+	var buf bytes.Buffer
+	clk := clock.NewAuto(time.Time{}) // golden runs never wait on real sleeps
+	out, err := newReporter(&buf, formatText, clk)
+	if err != nil {
+		return "", err
+	}
+	out.begin(sec)
+	sec.run(newSession(out, clk, seed, true))
+	out.end()
+	return normalize(sec, buf.String()), nil
+}
+
+// runGolden checks (or with update rewrites) the golden file of every selected section
+// It reports one line per section to w and returns false if any section did not match
+func runGolden(w io.Writer, dir string, selected []section, seed int64, update bool) (bool, error) {
+	ok := true
+	for _, sec := range selected {
+		got, err := renderGolden(sec, seed)
+		if err != nil {
+			return false, err
+		}
+		path := filepath.Join(dir, sec.name+".golden")
+		if update {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return false, err
+			}
+			if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+				return false, err
+			}
+			fmt.Fprintf(w, "updated %s\n", path)
+			continue
+		}
+
+		want, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		if diff := firstDiff(string(want), got); diff != "" {
+			ok = false
+			fmt.Fprintf(w, "FAIL %s\n%s", sec.name, diff)
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s\n", sec.name)
+	}
+	return ok, nil
+}
+
+// firstDiff describes the first line where want and got differ, or returns "" if they are equal
+func firstDiff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("    line %d\n    want: %q\n    got:  %q\n", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the current output")
+
+// goldenSeed is the seed the golden files were written with, the same as the --seed default
+const goldenSeed = 1
+
+// TestGolden runs every registered section on a virtual clock and compares its output with testdata/golden
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "golden")
+	for _, sec := range sections {
+		t.Run(sec.name, func(t *testing.T) {
+			got, err := renderGolden(sec, goldenSeed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, sec.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test ./cmd/main -update to create it)", err)
+			}
+			if diff := firstDiff(string(want), got); diff != "" {
+				t.Errorf("output differs from %s\n%s", path, diff)
+			}
+		})
+	}
+}
+
+// TestNormalize checks the masking that lets output with addresses, durations and go routines repeat
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		concurrent bool
+		in, want   string
+	}{
+		{"address", false, "p = 0xc000012345\n", "p = 0xADDR\n"},
+		{"duration", false, "took 1.5s, then 250ms and 2m3.5s\n", "took DURATION, then DURATION and DURATION\n"},
+		{"plain numbers", false, "id 42 costs 3.50\n", "id 42 costs 3.50\n"},
+		{"concurrent order", true, "b\nc\na\n", "a\nb\nc\n"},
+		{"sequential order", false, "b\nc\na\n", "b\nc\na\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(section{concurrent: tt.concurrent}, tt.in); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
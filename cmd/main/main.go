@@ -2,87 +2,54 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
-)
-
-// Go Routine variables
-var waitGroup = sync.WaitGroup{} // create a waitgroup to wait for all go routines to finish, essentially a counter
-// Add waitgroup.Add(1) before starting a go routine
-// Add waitgroup.Wait() in main function to wait for all go routines to finish
-// Add waitgroup.Done() at the end of the go routine (within function) to decrement the counter
-
-// Simulated database data
-var dbData = []string{"id1", "id2", "id3", "id4", "id5"}
 
-// Mutex / Locks
-var mutex = sync.Mutex{} // create a mutex to lock access to shared resources in go routines
-var dbResults = []string{}
-
-// Go Routine variables end
-
-/*
+	"github.com/donnebaldemeca/GoBasics/api"
+	"github.com/donnebaldemeca/GoBasics/clock"
+	"github.com/donnebaldemeca/GoBasics/cost"
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/fleetrpc"
+	"github.com/donnebaldemeca/GoBasics/pool"
+	"github.com/donnebaldemeca/GoBasics/retry"
+	"github.com/donnebaldemeca/GoBasics/stats"
+	"github.com/donnebaldemeca/GoBasics/store"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
 
-	Structs and Interfaces
-	Declaration phrasing:
-	type structName struct {
-		fieldName fieldType (values default to zero values if not initialized)
-		...
+// canDrive takes the vehicle.Engine interface as a parameter, so it works with every engine type
+// vehicle.CanDrive does the work and returns a verdict, canDrive only prints it
+func canDrive(s *session, e vehicle.Engine, miles float64) {
+	verdict, err := vehicle.CanDrive(e, miles)
+	if err != nil {
+		s.out.printf("canDrive", err.Error(), "Error: %v\n", err)
+		return
 	}
-
-*/
-
-type gasEngine struct {
-	mpg       uint8
-	gallons   uint8
-	ownerInfo engineOwner // fields can be other structs, creating nested structs
-}
-
-// func (receiverName receiverType) methodName(parameterName parameterType) returnType { ... }
-func (g gasEngine) milesLeft() uint8 { // method with receiver of type gasEngine, directly associated with the struct, and can access its fields
-	return g.mpg * g.gallons
-}
-
-type engineOwner struct {
-	name string
-	ownerID
-}
-
-type ownerID struct {
-	id uint8
-}
-
-type electricEngine struct {
-	mpkwh     uint8
-	kwh       uint8
-	ownerInfo engineOwner
-}
-
-func (e electricEngine) milesLeft() uint8 { // method with receiver of type electricEngine
-	return e.mpkwh * e.kwh
-}
-
-type engine interface { // interface type, defines a set of methods that a type must implement to satisfy the interface
-	milesLeft() uint8 // any type that has a milesLeft method with this signature satisfies the engine interface
-}
-
-func canDrive(e engine, miles uint8) { // function that takes an engine interface as a parameter
-	if miles <= e.milesLeft() {
-		fmt.Println("You can drive!")
-	} else {
-		fmt.Println("You need to refuel/recharge!")
+	if verdict.Feasible {
+		s.out.println("canDrive", verdict, "You can drive!")
+		return
+	}
+	s.out.println("canDrive", verdict, "You need to refuel/recharge!")
+	for _, d := range verdict.Needed {
+		s.out.printf("canDrive needed", d, "Add %s of %s to cover the last %s\n", vehicle.FormatAmount(d.Amount, d.Source, s.units), d.Source, vehicle.FormatDistance(d.Miles, s.units))
 	}
 }
 
-func main() {
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Variables and Data Types")
-	fmt.Println(strings.Repeat("-", 50))
+func variablesSection(s *session) {
 	/*
 		Variables are statically & strongly typed
 
@@ -95,8 +62,8 @@ func main() {
 	myVariable = "Hello, Go!" // Assign a value to the variable
 
 	// Printing using fmt package
-	fmt.Println(myVariable)
-	fmt.Println("My Number: ", myNumber)
+	s.out.show("myVariable", myVariable)
+	s.out.println("myNumber", myNumber, "My Number: ", myNumber)
 
 	// Data types
 	var intNum int = -8 // integers can be int8, int16, int32, int64 depending on how many bits
@@ -107,7 +74,7 @@ func main() {
 	var float64Num float64 = 12345678.9 // floating-point numbers must be specified as float32 or float64
 	var float32Num float32 = 12345678.9 // does not always print as the assigned value, but rather how it is stored in memory due to precision, but float64 is more precise
 
-	fmt.Println("int:", intNum, "\nuint:", uintNum, "\nfloat64:", float64Num, "\nfloat32:", float32Num)
+	s.out.println("numbers", []any{intNum, uintNum, float64Num, float32Num}, "int:", intNum, "\nuint:", uintNum, "\nfloat64:", float64Num, "\nfloat32:", float32Num)
 
 	/*
 		Choose the right type based on the need for precision and memory usage
@@ -119,33 +86,33 @@ func main() {
 World!` // backticks for multiline strings
 	var myConcatString string = "Hello," + " " + "World!" // string concatenation
 
-	fmt.Println(myString)
-	fmt.Println(myMultilineString)
-	fmt.Println(myConcatString)
+	s.out.show("myString", myString)
+	s.out.show("myMultilineString", myMultilineString)
+	s.out.show("myConcatString", myConcatString)
 
-	fmt.Println(len("γ"))                    // length of string in bytes, this is lowercase gamma appears as 2 bytes
-	fmt.Println(utf8.RuneCountInString("γ")) // length of string in runes(characters), this is 1 rune
+	s.out.show("len(γ)", len("γ"))                      // length of string in bytes, this is lowercase gamma appears as 2 bytes
+	s.out.show("runes(γ)", utf8.RuneCountInString("γ")) // length of string in runes(characters), this is 1 rune
 
-	var myRune rune = 'γ'       // rune is an alias for int32, represents a Unicode decimal code or HTML entity (&#947) of a Unicode character
-	fmt.Println(myRune)         // prints the Unicode decimal code value1 of the rune
-	fmt.Println(string(myRune)) // converts the rune back to a string and prints the character
+	var myRune rune = 'γ'                        // rune is an alias for int32, represents a Unicode decimal code or HTML entity (&#947) of a Unicode character
+	s.out.show("myRune", myRune)                 // prints the Unicode decimal code value1 of the rune
+	s.out.show("string(myRune)", string(myRune)) // converts the rune back to a string and prints the character
 
 	var myBoolean bool = true // boolean type
-	fmt.Println(myBoolean)
+	s.out.show("myBoolean", myBoolean)
 
 	var myByte byte = 255 // byte is an alias for uint8, represents a single byte of data (0-255)
-	fmt.Println(myByte)
+	s.out.show("myByte", myByte)
 
 	var myComplex complex64 = 1 + 2i // complex numbers, can be complex64 or complex128
-	fmt.Println(myComplex)
+	s.out.show("myComplex", myComplex)
 
-	var myPointer *string = &myVariable // pointer type, holds the memory address of a variable
-	fmt.Println(myPointer)              // prints the memory address of myVariable
-	fmt.Println(*myPointer)             // dereference the pointer to get the value of myVariable
-	fmt.Println(&myVariable)            // prints the memory address of myVariable
+	var myPointer *string = &myVariable    // pointer type, holds the memory address of a variable
+	s.out.show("myPointer", myPointer)     // prints the memory address of myVariable
+	s.out.show("*myPointer", *myPointer)   // dereference the pointer to get the value of myVariable
+	s.out.show("&myVariable", &myVariable) // prints the memory address of myVariable
 
 	var myPointerX *string
-	fmt.Println(myPointerX) // prints <nil> because the pointer is not initialized
+	s.out.show("myPointerX", myPointerX) // prints <nil> because the pointer is not initialized
 	/*
 		When variables are initialized without a value default values are assigned
 
@@ -159,10 +126,10 @@ World!` // backticks for multiline strings
 
 	var myInferedInt = 42           // type inferred as int without explicit type declaration
 	myInferedString := "Hello, Go!" // shorthand for declaring and initializing a variable, type inferred
-	fmt.Println(myInferedInt, myInferedString)
+	s.out.println("inferred", []any{myInferedInt, myInferedString}, myInferedInt, myInferedString)
 
 	var1, var2 := "Hello", 42 // multiple variable declaration and initialization
-	fmt.Println(var1, var2)
+	s.out.println("var1, var2", []any{var1, var2}, var1, var2)
 
 	/*
 		Can use short-hand declaration when type is obvious, otherwise use explicit declaration
@@ -170,7 +137,7 @@ World!` // backticks for multiline strings
 	*/
 
 	const myConst string = "constant value" // constant variable, cannot be changed after declaration
-	fmt.Println(myConst)
+	s.out.show("myConst", myConst)
 
 	/*
 		Constants can be character, string, boolean, or numeric values
@@ -178,21 +145,19 @@ World!` // backticks for multiline strings
 		Constants must be initialized with a value
 		Constants are often used for configuration values that should not change
 	*/
-	printMyName("Donne")
+	printMyName(s, "Donne")
 	var result, remainder, err = intDivision(10, 2)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		s.out.printf("error", err.Error(), "Error: %v\n", err)
 	} else if remainder == 0 {
-		fmt.Printf("10 divided by 3 is %v with no remainder\n", result)
+		s.out.printf("intDivision", []int{result, remainder}, "10 divided by 3 is %v with no remainder\n", result)
 	} else {
-		fmt.Printf("10 divided by 3 is %v with a remainder of %d\n", result, remainder)
+		s.out.printf("intDivision", []int{result, remainder}, "10 divided by 3 is %v with a remainder of %d\n", result, remainder)
 		// %v is a placeholder 'verb' for any value, %d is a placeholder 'verb' for any decimal value base 10
 	}
+}
 
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Data Structures")
-	fmt.Println(strings.Repeat("-", 50))
-
+func dataStructuresSection(s *session) {
 	/*
 
 		Data Structures
@@ -204,34 +169,34 @@ World!` // backticks for multiline strings
 	// var intArr = [...]int32{1, 2, 3} // type inferred, size inferred
 	// intArr := [...]int32{1, 2, 3} // shorthand declaration, type inferred, size inferred
 
-	fmt.Println(intArr[0])   // access first element
-	fmt.Println(intArr[1:3]) // slice from index 1 to 2 (1 is inclusive, 3 is exclusive)
+	s.out.show("intArr[0]", intArr[0])     // access first element
+	s.out.show("intArr[1:3]", intArr[1:3]) // slice from index 1 to 2 (1 is inclusive, 3 is exclusive)
 
 	// Stored in continguous memory locations 32 bits (4 bytes) each,  4 bytes apart
-	fmt.Println(&intArr[0]) // access first element memory location
-	fmt.Println(&intArr[1]) // access second element memory location
-	fmt.Println(&intArr[2]) // access third element memory location
+	s.out.show("&intArr[0]", &intArr[0]) // access first element memory location
+	s.out.show("&intArr[1]", &intArr[1]) // access second element memory location
+	s.out.show("&intArr[2]", &intArr[2]) // access third element memory location
 
 	// Slices - dynamic size, same type, indexable, contiguous memory, wrapper around arrays
 	var intSlice []int32 = []int32{1, 2, 3} // type inferred, size dynamic
 	// intSlice := []int32{1, 2, 3} // shorthand declaration, type inferred, size dynamic
-	fmt.Println(intSlice)
-	fmt.Printf("Length: %d, Capacity: %d\n", len(intSlice), cap(intSlice)) // length and capacity of slice
+	s.out.show("intSlice", intSlice)
+	s.out.lenCap("intSlice", len(intSlice), cap(intSlice)) // length and capacity of slice
 
 	intSlice = append(intSlice, 4) // append to slice, increases size dynamically
 	// creates a new underlying array if the existing array is not large enough to accommodate the new element
-	fmt.Println(intSlice)
-	fmt.Printf("Length: %d, Capacity: %d\n", len(intSlice), cap(intSlice)) // length and capacity of slice after append
+	s.out.show("intSlice", intSlice)
+	s.out.lenCap("intSlice", len(intSlice), cap(intSlice)) // length and capacity of slice after append
 
 	var newIntSlice []int32 = []int32{5, 10}
 	newIntSlice = append(newIntSlice, intSlice...) // append intSlice to newIntSlice, ... is the spread operator
-	fmt.Println(newIntSlice)
-	fmt.Printf("Length: %d, Capacity: %d\n", len(newIntSlice), cap(newIntSlice))
+	s.out.show("newIntSlice", newIntSlice)
+	s.out.lenCap("newIntSlice", len(newIntSlice), cap(newIntSlice))
 
 	var newerIntSlice []int32 = make([]int32, 2, 5) // using the make functrion, creates a slice with length 2 and capacity 5
 	// if capacity is not specified, it defaults to the length
-	fmt.Println(newerIntSlice)
-	fmt.Printf("Length: %d, Capacity: %d\n", len(newerIntSlice), cap(newerIntSlice))
+	s.out.show("newerIntSlice", newerIntSlice)
+	s.out.lenCap("newerIntSlice", len(newerIntSlice), cap(newerIntSlice))
 
 	// Maps - key-value pairs, dynamic size, unordered, reference type
 	var myMap map[string]uint8 = make(map[string]uint8) // type inferred, size dynamic
@@ -239,36 +204,35 @@ World!` // backticks for multiline strings
 	myMap["Donne"] = 32
 	myMap["Alice"] = 28
 	myMap["Bob"] = 25
-	fmt.Println(myMap)
+	s.out.show("myMap", myMap)
 
 	// Maps also return a boolean indicating if the key exists
 	mapKey := "Jake"
 	age, exists := myMap[mapKey]
 	if exists {
-		fmt.Printf("%s is %d years old\n", mapKey, age)
+		s.out.printf(mapKey, age, "%s is %d years old\n", mapKey, age)
 	} else {
-		fmt.Printf("%s not found in map\n", mapKey)
+		s.out.printf(mapKey, nil, "%s not found in map\n", mapKey)
 	}
 
 	delete(myMap, "Bob") // delete key-value pair from map
-	fmt.Println(myMap)
-	fmt.Printf("Length: %d\n", len(myMap)) // length of map
+	s.out.show("myMap", myMap)
+	s.out.printf("len(myMap)", len(myMap), "Length: %d\n", len(myMap)) // length of map
 
 	// Loops
-	for name := range myMap {
-		fmt.Printf("Name: %s, Age: %d\n", name, myMap[name])
+	for _, name := range mapKeys(s, myMap) {
+		s.out.printf(name, myMap[name], "Name: %s, Age: %d\n", name, myMap[name])
 	}
 	// In maps the order of iteration is not guaranteed to be the same each time
+	// for name := range myMap { ... } ranges over the keys directly, mapKeys only sorts them when a --seed is given
 	// Go does not have a while loop, but can use for loop to achieve the same functionality
 	for i := 0; i < 5; i++ {
-		fmt.Println("Iteration:", i)
+		s.out.println("iteration", i, "Iteration:", i)
 	}
 	// Same as while i < 5
+}
 
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Performance Test")
-	fmt.Println(strings.Repeat("-", 50))
-
+func performanceSection(s *session) {
 	/*
 		Performance Test
 	*/
@@ -276,13 +240,13 @@ World!` // backticks for multiline strings
 	var perfSlice1 = []int{}
 	var perfSlice2 = make([]int, 0, allocationSize)
 
-	fmt.Printf("Time taken for slice without pre-allocated capacity: %v\n", timeLoop(perfSlice1, allocationSize))
-	fmt.Printf("Time taken for slice with pre-allocated capacity: %v\n", timeLoop(perfSlice2, allocationSize))
-
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Strings, Runes, and Bytes")
-	fmt.Println(strings.Repeat("-", 50))
+	withoutCapacity := timeLoop(perfSlice1, allocationSize)
+	s.out.printf("without pre-allocated capacity", withoutCapacity, "Time taken for slice without pre-allocated capacity: %v\n", withoutCapacity)
+	withCapacity := timeLoop(perfSlice2, allocationSize)
+	s.out.printf("with pre-allocated capacity", withCapacity, "Time taken for slice with pre-allocated capacity: %v\n", withCapacity)
+}
 
+func stringsSection(s *session) {
 	/*
 		Strings, Runes, and Bytes
 		Strings are immutable, cannot change individual characters
@@ -290,24 +254,24 @@ World!` // backticks for multiline strings
 		Bytes are used to represent raw binary data
 	*/
 
-	var thisString = "Résumé"                // string with Unicode characters
-	var indexed = thisString[0]              // index the first character, returns a byte value
-	fmt.Printf("%v, %T\n", indexed, indexed) // prints the byte value and type of the first character
-	fmt.Println(string(indexed))             // converts the byte back to a string and prints the character
-	for i, v := range thisString {           // range over the string, returns the index and byte value of each character
-		fmt.Printf("Index: %d, Value: %v\n", i, v) // using %c results in rune using %v results in byte value
+	var thisString = "Résumé"                                            // string with Unicode characters
+	var indexed = thisString[0]                                          // index the first character, returns a byte value
+	s.out.printf("thisString[0]", indexed, "%v, %T\n", indexed, indexed) // prints the byte value and type of the first character
+	s.out.show("string(indexed)", string(indexed))                       // converts the byte back to a string and prints the character
+	for i, v := range thisString {                                       // range over the string, returns the index and byte value of each character
+		s.out.printf(fmt.Sprintf("thisString[%d]", i), v, "Index: %d, Value: %v\n", i, v) // using %c results in rune using %v results in byte value
 	}
 	// Index 2 is skipped because index 1 and 2 are part of the same Unicode character
 
-	var thisRune = 'a'                                       // rune must be enclosed in single quotes
-	fmt.Printf("%v, %T, %c\n", thisRune, thisRune, thisRune) // prints the rune value, type, and character
+	var thisRune = 'a'                                                               // rune must be enclosed in single quotes
+	s.out.printf("thisRune", thisRune, "%v, %T, %c\n", thisRune, thisRune, thisRune) // prints the rune value, type, and character
 
 	var strSlice = []string{"s", "t", "r", "i", "n", "g"} // slice of strings
 	var concatStr = ""
 	for i := range strSlice { // creates a new string every iteration because strings are immutable
 		concatStr += strSlice[i]
 	}
-	fmt.Println(concatStr)
+	s.out.show("concatStr", concatStr)
 
 	// More efficient way to concatenate strings using strings.Builder
 	var sb strings.Builder
@@ -315,45 +279,361 @@ World!` // backticks for multiline strings
 		sb.WriteString(strSlice[i])
 	}
 	var catStr = sb.String() // convert the builder to a string
-	fmt.Println(catStr)
-
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Structs, Interfaces, and Methods")
-	fmt.Println(strings.Repeat("-", 50))
+	s.out.show("catStr", catStr)
+}
 
+func structsSection(s *session) {
 	// Structs, Interfaces, and Methods
-	var myEngine gasEngine = gasEngine{mpg: 25, gallons: 15} // initialize struct
-	// myEngine := gasEngine{25, 15} // shorthand declaration, order matters for field values when initializing without field names
+	// The engine types live in the vehicle package, so other programs can import them too
+	var myEngine vehicle.GasEngine = vehicle.GasEngine{MPG: 25, Gallons: 15} // initialize struct
+	// myEngine := vehicle.GasEngine{25, 15, vehicle.EngineOwner{}} // shorthand declaration, order matters and every field must be given when initializing without field names
 	// if no value is provided for a field, it defaults to the zero value of the field type
-	// myEngine.mpg = 25 // can assign values to struct fields individually
-	// myEngine.gallons = 15
-	fmt.Printf("My engine gets %d miles per gallon and has a %d gallon tank\n", myEngine.mpg, myEngine.gallons)
-
-	myEngine.ownerInfo = engineOwner{name: "Donne", ownerID: ownerID{id: 1}} // initialize nested struct field
-	fmt.Printf("Engine owner is %s, ID %d\n", myEngine.ownerInfo.name, myEngine.ownerInfo.id)
+	// myEngine.MPG = 25 // can assign values to struct fields individually
+	// myEngine.Gallons = 15
+	s.out.printf("myEngine", myEngine, "My engine gets %v miles per gallon and has a %v gallon tank\n", myEngine.MPG, myEngine.Gallons)
+
+	myEngine.OwnerInfo = vehicle.EngineOwner{Name: "Donne", OwnerID: vehicle.OwnerID{ID: 1}} // initialize nested struct field
+	s.out.printf("myEngine.OwnerInfo", myEngine.OwnerInfo, "Engine owner is %s, ID %d\n", myEngine.OwnerInfo.Name, myEngine.OwnerInfo.ID)
+
+	var myInfo vehicle.EngineOwner = vehicle.EngineOwner{Name: "Alice", OwnerID: vehicle.OwnerID{ID: 2}} // initialize nested struct with nested struct field
+	s.out.printf("myInfo", myInfo, "Owner is %s, ID %d\n", myInfo.Name, myInfo.ID)                       // can also access nested struct fields directly myInfo.ID instead of myInfo.OwnerID.ID
+
+	// IDs can be allocated instead of hand-assigned, any type with a NextID method satisfies vehicle.IDAllocator
+	var allocators = []vehicle.IDAllocator{vehicle.NewSequentialIDs(5), vehicle.RandomIDs{Rand: s.rand(0)}}
+	for _, ids := range allocators {
+		id, err := ids.NextID()
+		if err != nil {
+			s.out.printf("NextID", err.Error(), "Error: %v\n", err)
+			continue
+		}
+		s.out.printf("NextID", id, "%T issued owner ID %v\n", ids, id)
+	}
 
-	var myInfo engineOwner = engineOwner{name: "Alice", ownerID: ownerID{id: 2}} // initialize nested struct with nested struct field
-	fmt.Printf("Owner is %s, ID %d\n", myInfo.name, myInfo.id)                   // can also access nested struct fields directly myInfo.id instead of myInfo.ownerID.id
+	// Named struct types can be reused and can have methods, so HydrogenEngine satisfies the Engine interface
+	var myHydrogenEngine = vehicle.HydrogenEngine{MilesPerKg: 60, Kg: 5, OwnerInfo: vehicle.EngineOwner{Name: "Bob", OwnerID: vehicle.OwnerID{ID: 3}}}
+	hydrogenRange, err := myHydrogenEngine.MilesLeft()
+	if err != nil {
+		s.out.printf("myHydrogenEngine.MilesLeft()", err.Error(), "Error: %v\n", err)
+	} else {
+		s.out.printf("myHydrogenEngine", myHydrogenEngine, "Hydrogen engine has %v kg of hydrogen and an estimated range of %v miles. Owner is %s, ID %d\n", myHydrogenEngine.Kg, hydrogenRange, myHydrogenEngine.OwnerInfo.Name, myHydrogenEngine.OwnerInfo.ID)
+	}
 
 	// Anonymous struct
-	var hydroEngine = struct { // no name for the struct type, cannot be reused
-		waterCapacity  uint8
-		estimatedRange uint16
-		ownerInfo      engineOwner
-	}{waterCapacity: 100, estimatedRange: 300, ownerInfo: engineOwner{name: "Bob", ownerID: ownerID{id: 3}}}
-	fmt.Printf("Hydro engine has %d gallons of water and an estimated range of %d miles. Owner is %s, ID %d\n", hydroEngine.waterCapacity, hydroEngine.estimatedRange, hydroEngine.ownerInfo.name, hydroEngine.ownerInfo.id)
+	var trip = struct { // no name for the struct type, cannot be reused
+		from, to string
+		miles    float64
+	}{from: "Oakland", to: "Sacramento", miles: 80}
+	s.out.printf("trip", trip, "Trip from %s to %s is %v miles\n", trip.from, trip.to, trip.miles)
+	canDrive(s, myHydrogenEngine, trip.miles)
+
+	// MilesLeft() returns a float64 and an error, so the full 15 gallon tank no longer overflows the way uint8 * uint8 did
+	milesLeft, err := myEngine.MilesLeft() // call method on struct
+	if err != nil {
+		s.out.printf("myEngine.MilesLeft()", err.Error(), "Error: %v\n", err)
+	} else {
+		s.out.printf("myEngine.MilesLeft()", milesLeft, "My gas engine can go %s before refueling\n", vehicle.FormatDistance(milesLeft, s.units))
+	}
+	canDrive(s, myEngine, 350) // trip distances are float64 too, so trips longer than 255 miles are fine
+
+	var myElectricEngine vehicle.ElectricEngine = vehicle.ElectricEngine{MPKWh: 3, KWh: 10, OwnerInfo: vehicle.EngineOwner{Name: "Eve", OwnerID: vehicle.OwnerID{ID: 4}}}
+	canDrive(s, myElectricEngine, 50) // pass struct that implements the Engine interface
+
+	// Embedding, HybridEngine embeds a GasEngine and an ElectricEngine and still satisfies the Engine interface
+	var myHybridEngine = vehicle.HybridEngine{
+		GasEngine:      vehicle.GasEngine{MPG: 40, Gallons: 10},
+		ElectricEngine: vehicle.ElectricEngine{MPKWh: 3, KWh: 12},
+		Order:          vehicle.ElectricFirst,
+		OwnerInfo:      vehicle.EngineOwner{Name: "Frank", OwnerID: vehicle.OwnerID{ID: 5}},
+	}
+	s.out.printf("myHybridEngine", myHybridEngine, "Hybrid engine has %v gallons at %v mpg and %v kWh at %v miles per kWh, drained %v\n", myHybridEngine.Gallons, myHybridEngine.MPG, myHybridEngine.KWh, myHybridEngine.MPKWh, myHybridEngine.Order) // promoted fields
+	canDrive(s, myHybridEngine, 50)
+	tripSources(s, myHybridEngine, 50)
+
+	// Engines store US units, the metric constructors convert L/100km and litres when the engine is declared
+	var myMetricEngine = vehicle.NewGasEngineMetric(6.5, 45, vehicle.EngineOwner{Name: "Greta", OwnerID: vehicle.OwnerID{ID: 6}})
+	for _, u := range []vehicle.UnitSystem{vehicle.Imperial, vehicle.Metric} {
+		s.out.printf("myMetricEngine "+u.String(), myMetricEngine, "Metric engine uses %s, %s in the tank\n", vehicle.FormatEfficiency(myMetricEngine.MPG, vehicle.Gasoline, u), vehicle.FormatAmount(myMetricEngine.Gallons, vehicle.Gasoline, u))
+	}
+	if verdict, err := vehicle.CanDrive(myMetricEngine, vehicle.KmToMiles(500)); err == nil {
+		s.out.println("myMetricEngine verdict", verdict, verdict.Format(s.units))
+	}
+}
+
+func lifecycleSection(s *session) {
+	// Driving and refuelling change the engine, so those methods have pointer receivers
+	var myEngine = vehicle.GasEngine{MPG: 25, Gallons: 15, TankGallons: 15}
+	err := myEngine.Drive(100) // myEngine is a variable, so Go calls (&myEngine).Drive(100) for us
+	reportEngine(s, "after driving 100 miles", &myEngine, err)
+
+	var engineCopy = myEngine // assigning a struct copies it
+	err = engineCopy.Drive(200)
+	reportEngine(s, "copy after driving 200 miles", &engineCopy, err)
+	reportEngine(s, "original is unchanged", &myEngine, nil)
+
+	// Only *GasEngine has Drive, so an interface holding a GasEngine value does not satisfy vehicle.Driver
+	var asValue vehicle.Engine = myEngine
+	var asPointer vehicle.Engine = &myEngine
+	_, valueDrives := asValue.(vehicle.Driver)
+	_, pointerDrives := asPointer.(vehicle.Driver)
+	s.out.printf("Driver", []bool{valueDrives, pointerDrives}, "GasEngine is a Driver: %v, *GasEngine is a Driver: %v\n", valueDrives, pointerDrives)
+
+	// Errors leave the engine unchanged, errors.Is finds the sentinel error inside the wrapped message
+	err = myEngine.Drive(1000)
+	reportEngine(s, "driving 1000 miles", &myEngine, err)
+	s.out.printf("errors.Is", errors.Is(err, vehicle.ErrRanDry), "Ran dry: %v\n", errors.Is(err, vehicle.ErrRanDry))
+	err = myEngine.Fill(vehicle.Gasoline, 20)
+	reportEngine(s, "adding 20 gallons", &myEngine, err)
+	err = myEngine.FillUp()
+	reportEngine(s, "after filling up", &myEngine, err)
+
+	var myElectricEngine = vehicle.ElectricEngine{MPKWh: 3, KWh: 10, BatteryKWh: 60}
+	err = myElectricEngine.Drive(24)
+	reportEngine(s, "after driving 24 miles", &myElectricEngine, err)
+	err = myElectricEngine.Recharge(20) // partial charge
+	reportEngine(s, "after a 20 kWh charge", &myElectricEngine, err)
+	err = myElectricEngine.Recharge(50)
+	reportEngine(s, "charging 50 kWh more", &myElectricEngine, err)
+}
+
+// reportEngine prints an engine's range after a step of the lifecycle demo, or the error the step returned
+func reportEngine(s *session, step string, e vehicle.Engine, err error) {
+	if err != nil {
+		s.out.printf(step, err.Error(), "%s: Error: %v\n", step, err)
+		return
+	}
+	left, err := e.MilesLeft()
+	if err != nil {
+		s.out.printf(step, err.Error(), "%s: Error: %v\n", step, err)
+		return
+	}
+	s.out.printf(step, e, "%s: %s left\n", step, vehicle.FormatDistance(left, s.units))
+}
 
-	myEngine.gallons = 3 // milesLeft() returns uint8, if gallon value was 15 it would overflow and return incorrect value
+func factoriesSection(s *session) {
+	// Engines can be built by name from a config file, vehicle.Kinds lists every registered type
+	s.out.println("kinds", vehicle.Kinds(), "Registered engine types:", vehicle.Kinds())
+	var configs = []string{
+		`{"type": "electric", "mpkwh": 3, "kwh": 10}`,
+		`{"type": "hybrid", "mpg": 40, "gallons": 10, "mpkwh": 3, "kwh": 12, "order": "gas-first"}`,
+		`{"type": "pedal", "mph": 12, "hours": 2.5}`, // registered by this program, not by the vehicle package
+		`{"type": "gas", "mpg": 25, "galons": 15}`,   // typo, unknown fields are an error
+		`{"type": "steam", "coal_kg": 200}`,
+	}
+	for _, config := range configs {
+		e, err := vehicle.FromJSON([]byte(config))
+		if err != nil {
+			s.out.printf("config", err.Error(), "%s\n  Error: %v\n", config, err)
+			continue
+		}
+		left, err := e.MilesLeft()
+		if err != nil {
+			s.out.printf("config", err.Error(), "%s\n  Error: %v\n", config, err)
+			continue
+		}
+		kind, _ := vehicle.KindOf(e)
+		s.out.printf("config", e, "%s\n  %s engine, %s left\n", config, kind, vehicle.FormatDistance(left, s.units))
+	}
+}
 
-	fmt.Printf("My gas engine can go %d miles before refueling\n", myEngine.milesLeft()) // call method on struct
+// pedalEngine shows how a program adds its own engine type
+// Registering it makes it available to vehicle.FromJSON, the fleet file and
+// fleet add --type pedal --set mph=12 --set hours=2.5, none of which know about it
+type pedalEngine struct {
+	MilesPerHour float64             `json:"mph"`
+	Hours        float64             `json:"hours"` // how long the rider can keep going
+	OwnerInfo    vehicle.EngineOwner `json:"owner"`
+}
 
-	var myElectricEngine electricEngine = electricEngine{mpkwh: 3, kwh: 10, ownerInfo: engineOwner{name: "Eve", ownerID: ownerID{id: 4}}}
-	canDrive(myElectricEngine, 50) // pass struct that implements the engine interface
+func (p pedalEngine) MilesLeft() (float64, error) {
+	if p.MilesPerHour < 0 || p.Hours < 0 {
+		return 0, vehicle.ErrNegative
+	}
+	return p.MilesPerHour * p.Hours, nil
+}
 
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Println("Pointers and Memory Management")
-	fmt.Println(strings.Repeat("-", 50))
+func init() {
+	vehicle.Register("pedal", pedalEngine{}, vehicle.JSONFactory[pedalEngine]())
+}
 
+func tripsSection(s *session) {
+	// Trip planning works on the Engine interface, so the same route can be planned for any engine type
+	var legs = []float64{60, 90, 40, 120}
+	var stops = []vehicle.Stop{
+		{Name: "Fresno", At: 1, Sources: []vehicle.Source{vehicle.Gasoline}},
+		{Name: "Bakersfield", At: 2, Sources: []vehicle.Source{vehicle.Gasoline, vehicle.Electricity}},
+		{Name: "Barstow", At: 3, Sources: []vehicle.Source{vehicle.Electricity}},
+	}
+	var engines = []vehicle.Engine{ // a slice of interface values can hold different engine types
+		vehicle.GasEngine{MPG: 25, Gallons: 3},
+		vehicle.ElectricEngine{MPKWh: 3, KWh: 30},
+		vehicle.HydrogenEngine{MilesPerKg: 60, Kg: 2},
+	}
+	for _, e := range engines {
+		itinerary, err := vehicle.Plan(e, legs, stops)
+		if err != nil {
+			s.out.printf("plan", err.Error(), "Error: %v\n", err)
+			continue
+		}
+		s.out.printf("itinerary", itinerary, "%T\n%s", e, itinerary.Format(s.units))
+
+		itineraryJSON, err := json.MarshalIndent(itinerary, "", "  ") // struct tags choose the JSON field names
+		if err != nil {
+			s.out.printf("plan", err.Error(), "Error: %v\n", err)
+			continue
+		}
+		s.out.printf("itinerary json", string(itineraryJSON), "%s\n", itineraryJSON)
+	}
+}
+
+func costsSection(s *session) {
+	// cost.Trip works on the Engine interface, so one comparison table covers every engine type
+	var names = []string{"gas", "electric", "hybrid", "hydrogen"}
+	var engines = []vehicle.Engine{
+		vehicle.GasEngine{MPG: 25, Gallons: 15},
+		vehicle.ElectricEngine{MPKWh: 3, KWh: 60},
+		vehicle.HybridEngine{GasEngine: vehicle.GasEngine{MPG: 40, Gallons: 10}, ElectricEngine: vehicle.ElectricEngine{MPKWh: 3, KWh: 12}, Order: vehicle.ElectricFirst},
+		vehicle.HydrogenEngine{MilesPerKg: 60, Kg: 5},
+	}
+	// Electricity is cheaper at night, so the same trip costs less when the battery is charged at 23:00
+	for _, hour := range []int{18, 23} {
+		at := time.Date(2024, time.January, 1, hour, 0, 0, 0, time.UTC)
+		rows := cost.Compare(names, engines, 100, cost.DefaultPrices, cost.DefaultFactors, at)
+		var table strings.Builder
+		if err := cost.WriteTable(&table, rows, s.units); err != nil {
+			s.out.printf("costs", err.Error(), "Error: %v\n", err)
+			continue
+		}
+		s.out.printf(fmt.Sprintf("costs at %02d:00", hour), rows, "Charging at %02d:00\n%s", hour, table.String())
+	}
+}
+
+func sortingSection(s *session) {
+	registry := fleet.New()
+	donne := vehicle.EngineOwner{Name: "Donne", OwnerID: vehicle.OwnerID{ID: 1}}
+	alice := vehicle.EngineOwner{Name: "Alice", OwnerID: vehicle.OwnerID{ID: 2}}
+	registry.Add(donne, vehicle.GasEngine{MPG: 25, Gallons: 12})
+	registry.Add(donne, vehicle.ElectricEngine{MPKWh: 3.5, KWh: 60})
+	registry.Add(alice, vehicle.ElectricEngine{MPKWh: 4, KWh: 20})
+	registry.Add(alice, vehicle.HydrogenEngine{MilesPerKg: 60, Kg: 5})
+
+	var queries = []struct {
+		name  string
+		query fleet.Query
+	}{
+		{"by range, sort.Stable with the sort.Interface type fleet.ByRange", fleet.Query{Sort: fleet.SortRange}},
+		{"by efficiency, best first, slices.SortFunc", fleet.Query{Sort: fleet.SortEfficiency, Descending: true}},
+		{"by owner name", fleet.Query{Sort: fleet.SortOwnerName}},
+		{"electric with at least 100 miles", fleet.Query{Kind: vehicle.KindElectric, MinRange: 100}},
+	}
+	for _, q := range queries {
+		items, err := registry.Select(q.query)
+		if err != nil {
+			s.out.printf("select", err.Error(), "Error: %v\n", err)
+			continue
+		}
+		var lines strings.Builder
+		for _, item := range items {
+			fmt.Fprintf(&lines, "  %-6s [%d] %-8s %s, %.1f MPGe\n", item.Owner.Name, item.Index, item.Kind, vehicle.FormatDistance(item.Range, s.units), item.MPGe)
+		}
+		s.out.printf("select", items, "%s:\n%s", q.name, lines.String())
+	}
+}
+
+func httpSection(s *session) {
+	// httptest.NewServer runs a real HTTP server on a local port, the requests below go over the network stack
+	server := httptest.NewServer(api.New(fleet.New(), nil)) // nil: nothing is saved
+	defer server.Close()
+
+	var requests = []struct{ method, path, body string }{
+		{"POST", "/owners", `{"name": "Donne"}`},
+		{"POST", "/owners/1/engines", `{"type": "gas", "mpg": 25, "gallons": 4}`},
+		{"POST", "/owners/1/engines", `{"type": "electric", "mpkwh": 3, "kwh": 10}`},
+		{"GET", "/owners/1/engines/0/miles-left", ""},
+		{"GET", "/owners/1/engines/1/can-drive?miles=50", ""},
+		{"POST", "/can-drive", `{"engine": {"type": "hydrogen", "miles_per_kg": 60, "kg": 2}, "miles": 100}`},
+		{"GET", "/owners/2", ""},
+		{"POST", "/owners/1/engines", `{"type": "steam"}`},
+		{"GET", "/engines", ""},
+	}
+	for _, req := range requests {
+		status, body, err := httpRequest(server.URL, req.method, req.path, req.body)
+		if err != nil {
+			s.out.printf("http", err.Error(), "%s %s: Error: %v\n", req.method, req.path, err)
+			continue
+		}
+		s.out.printf("http", json.RawMessage(body), "%s %s -> %d %s\n  %s\n", req.method, req.path, status, http.StatusText(status), body)
+	}
+}
+
+// httpRequest sends one request and returns the status code and the response body without its trailing newline
+func httpRequest(baseURL, method, path, body string) (int, string, error) {
+	req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close() // always close the body, or the connection cannot be reused
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, strings.TrimSpace(string(data)), nil
+}
+
+func rpcSection(s *session) {
+	// The JSON-RPC server listens on a free local port, port 0 lets the operating system pick one
+	registry := fleet.New()
+	owner := vehicle.EngineOwner{Name: "Donne", OwnerID: vehicle.OwnerID{ID: 1}}
+	registry.Add(owner, vehicle.GasEngine{MPG: 25, Gallons: 4, TankGallons: 15, OwnerInfo: owner})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.out.printf("rpc", err.Error(), "Error: %v\n", err)
+		return
+	}
+	defer listener.Close()
+	go fleetrpc.Serve(listener, fleetrpc.NewService(registry, nil))
+
+	client, err := fleetrpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		s.out.printf("rpc", err.Error(), "Error: %v\n", err)
+		return
+	}
+	defer client.Close()
+
+	engine := fleetrpc.EngineRef{Owner: "1", Index: 0}
+	if left, err := client.MilesLeft(engine); err == nil {
+		s.out.printf("Fleet.MilesLeft", left, "Fleet.MilesLeft: %.1f miles\n", left)
+	}
+	if verdict, err := client.CanDrive(engine, 250); err == nil {
+		s.out.printf("Fleet.CanDrive", verdict, "Fleet.CanDrive 250 miles: %+v\n", verdict)
+	}
+	if refuelled, err := client.Refuel(engine, vehicle.Gasoline, 8); err == nil {
+		s.out.printf("Fleet.Refuel", refuelled, "Fleet.Refuel 8 gallons: %.1f miles left\n", refuelled.MilesLeft)
+	}
+	if engines, err := client.List("1"); err == nil {
+		s.out.printf("Fleet.List", engines, "Fleet.List owner 1: %d engine(s), gallons %v\n", len(engines), engines[0].Engine["gallons"])
+	}
+	// Errors travel back as text, errors.Is cannot see through them any more
+	_, err = client.MilesLeft(fleetrpc.EngineRef{Owner: "2"})
+	s.out.printf("rpc error", err.Error(), "Fleet.MilesLeft owner 2: Error: %v (%T)\n", err, err)
+}
+
+// tripSources prints which energy sources a trip would use, for engines that can tell
+func tripSources(s *session, e vehicle.Engine, miles float64) {
+	draws, err := vehicle.Sources(e, miles)
+	if err != nil {
+		s.out.printf("tripSources", err.Error(), "Error: %v\n", err)
+		return
+	}
+	for _, d := range draws {
+		s.out.printf("tripSources", d, "%s on %s, using %s\n", vehicle.FormatDistance(d.Miles, s.units), d.Source, vehicle.FormatAmount(d.Amount, d.Source, s.units))
+	}
+}
+
+func pointersSection(s *session) {
 	/*
 
 		Pointers and Memory Management
@@ -364,31 +644,33 @@ World!` // backticks for multiline strings
 	// pointer will default to nil if not initialized
 	// pointer points to empty memory location with size of int32 (4 bytes)
 	var integer32 int32 = 3
-	fmt.Printf("The value pointer points to is: %v\n", *pointer)       // dereference pointer to get value, defaults to 0
-	fmt.Printf("The memory address of pointer is: %v\n", pointer)      // prints memory address
-	fmt.Printf("The value of integer32 is: %v\n", integer32)           // defaults to 0
-	pointer = &integer32                                               // assign the address of integer32 to pointer
-	fmt.Printf("The value pointer points to is: %v\n", *pointer)       // dereference pointer to get value
-	fmt.Printf("The memory address of pointer is: %v\n", pointer)      // prints memory address
-	fmt.Printf("The memory address of integer32 is: %v\n", &integer32) // prints memory address of integer32
-	*pointer = 10                                                      // change the value at the memory address pointer points to
-	fmt.Printf("The value pointer points to is: %v\n", *pointer)       // dereference pointer to get value
-	fmt.Printf("The value of integer32 is: %v\n", integer32)           // integer32 value has changed to 10
+	s.out.printf("*pointer", *pointer, "The value pointer points to is: %v\n", *pointer)           // dereference pointer to get value, defaults to 0
+	s.out.printf("pointer", pointer, "The memory address of pointer is: %v\n", pointer)            // prints memory address
+	s.out.printf("integer32", integer32, "The value of integer32 is: %v\n", integer32)             // defaults to 0
+	pointer = &integer32                                                                           // assign the address of integer32 to pointer
+	s.out.printf("*pointer", *pointer, "The value pointer points to is: %v\n", *pointer)           // dereference pointer to get value
+	s.out.printf("pointer", pointer, "The memory address of pointer is: %v\n", pointer)            // prints memory address
+	s.out.printf("&integer32", &integer32, "The memory address of integer32 is: %v\n", &integer32) // prints memory address of integer32
+	*pointer = 10                                                                                  // change the value at the memory address pointer points to
+	s.out.printf("*pointer", *pointer, "The value pointer points to is: %v\n", *pointer)           // dereference pointer to get value
+	s.out.printf("integer32", integer32, "The value of integer32 is: %v\n", integer32)             // integer32 value has changed to 10
 
 	// Pointers and slices
 	// Slices use pointers internally to reference the underlying array
 	var exampleSlice = []int{1, 2, 3}
 	var sliceCopy = exampleSlice // creates a copy of the slice header, but both slices point to the same underlying array
 	sliceCopy[0] = 10            // changing the value of sliceCopy also changes exampleSlice
-	fmt.Println("exampleSlice:", exampleSlice)
+	s.out.println("exampleSlice", exampleSlice, "exampleSlice:", exampleSlice)
 
 	// Pointers and functions
 	var floatArray = [5]float64{1, 2, 3, 4, 5}
-	fmt.Println("Original array:", floatArray)
+	s.out.println("floatArray", floatArray, "Original array:", floatArray)
 	squaredArray := squareArray(&floatArray) // passing array by reference using pointer, instead of by value, thus saving memory
-	fmt.Println("Squared array returned from function:", squaredArray)
-	fmt.Println("Original array after function call:", floatArray) // original array is unchanged
+	s.out.println("squaredArray", squaredArray, "Squared array returned from function:", squaredArray)
+	s.out.println("floatArray", floatArray, "Original array after function call:", floatArray) // original array is unchanged
+}
 
+func goroutinesSection(s *session) {
 	/*
 
 		Go routines
@@ -397,28 +679,141 @@ World!` // backticks for multiline strings
 
 	*/
 
-	t0 := time.Now()
-	for i := 0; i < len(dbData); i++ {
-		waitGroup.Add(1) // increment the waitgroup counter before starting a go routine
-
-		// dbCall(i) // sequential calls, takes longer
-		go dbCall(i) // concurrent calls, takes less time, use 'go' keyword infront of function
-		// go routines run in the background, main function may exit before they complete, so a waitgroup or sleep may be needed to wait for them to finish
-		// dbCall function calls waitGroup.Done() to decrement the counter when it completes
+	// Simulated databases, a Store hides where the data lives so the same calls work on any of them
+	db := store.NewMemory()
+	for n := 1; n <= s.db.records; n++ {
+		id := fmt.Sprintf("id%d", n)
+		db.Put(id, "record "+id)
 	}
-	waitGroup.Wait() // wait for all go routines to finish
-	fmt.Printf("Sequential DB calls took: %v\n", time.Since(t0))
+	keys, err := db.List()
+	if err != nil {
+		s.out.printf("db", err.Error(), "Error: %v\n", err)
+		return
+	}
+	slices.SortFunc(keys, byIDNumber)    // List sorts as text, id10 before id2
+	demoKeys := keys[:min(len(keys), 5)] // the lock and retry demos below only need a handful of records
+
+	// Context / cancellation
+	// A context carries a deadline and a Done channel, every call started with it stops waiting once it is done
+	// clock.WithTimeout measures the timeout on the session clock, so it passes in virtual time with --fast
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.db.deadline > 0 {
+		ctx, cancel = clock.WithTimeout(ctx, s.clock, s.db.deadline) // the whole fan-out gets --db-deadline
+	}
+	defer cancel() // always release a context's timer, even when it has already expired
+
+	s.out.printf("db mode", s.db.mode, "DB fan-out: %s, %d records, %s\n", s.db.describe(), len(keys), s.db.describeDeadlines())
+	t0 := s.clock.Now()                         // the session clock is the wall clock, or a virtual one with --fast
+	fetched, took := dbFanOut(ctx, s, db, keys) // cannot hang, every call returns by its deadline at the latest
+	elapsed := s.clock.Since(t0)
+	s.out.printf("dbCall total", elapsed, "DB calls (%s) took: %v\n", s.db.describe(), elapsed)
+
+	// Run concurrently, the fan-out lasts as long as its slowest call, so the tail of the latency model decides the total
+	p50, _ := stats.Percentile(took, 50)
+	p99, _ := stats.Percentile(took, 99)
+	_, slowest, _ := stats.MinMax(took)
+	s.out.printf("dbCall latency", slowest, "Call latency (%v): median %v, p99 %v, slowest %v\n",
+		s.db.latency, time.Duration(p50).Round(time.Millisecond), time.Duration(p99).Round(time.Millisecond), slowest.Round(time.Millisecond))
+
+	var finished []string
+	cancelled, failed := 0, 0
+	for _, r := range fetched {
+		switch {
+		case r.Err == nil:
+			finished = append(finished, r.Job.key)
+		case errors.Is(r.Err, errDBCancelled):
+			cancelled++
+		default:
+			failed++
+		}
+	}
+	if len(keys) <= dbPrintLimit {
+		s.out.printf("db partial results", finished, "Finished in time: %v\n", finished) // the partial results are still usable
+	}
+	s.out.printf("db cancelled", cancelled, "Cancelled: %d\n", cancelled)
+	s.out.printf("dbCall failed", failed, "Failed DB calls: %d\n", failed)
+
+	// Worker pool
+	// Run hands back the results in job order whatever order the workers finished in, each result carries its own error
+	// "missing" is outside the id<N> keys the database is filled with, so its call fails without stopping the others whatever --db-records is
+	jobs := dbJobs(append(slices.Clone(demoKeys), "missing"))
+	workers := pool.New(2, func(ctx context.Context, job dbJob) (string, error) {
+		return dbCall(ctx, s, db, job.index, job.key)
+	})
+	t3 := s.clock.Now()
+	for _, r := range workers.Run(context.Background(), jobs) {
+		if r.Err != nil {
+			s.out.printf("pool result", r.Err.Error(), "Pool job %d (%s): Error: %v\n", r.Index, r.Job.key, r.Err)
+			continue
+		}
+		s.out.printf("pool result", r.Value, "Pool job %d (%s): %s\n", r.Index, r.Job.key, r.Value)
+	}
+	elapsed = s.clock.Since(t3)
+	s.out.printf("pool total", elapsed, "Pool of %d workers took: %v\n", workers.Workers(), elapsed)
+
+	var waitGroup = sync.WaitGroup{}
 
 	// Mutex / Locks
-	t1 := time.Now()
-	for i := 0; i < len(dbData); i++ {
+	// Every go routine writes into the same results store, the store's own lock keeps the writes from colliding
+	// It is a file this time, dbCallMutexLock only sees the Store interface so it does not notice
+	dir, err := os.MkdirTemp("", "gobasics-db")
+	if err != nil {
+		s.out.printf("db", err.Error(), "Error: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	results := store.NewFile(filepath.Join(dir, "results.json"))
+
+	t1 := s.clock.Now()
+	for i, key := range demoKeys {
 		waitGroup.Add(1)
-		go dbCallMutexLock(i)
-		// dbCallMutexLock function uses mutex to lock access to shared resource (dbResults slice) when writing to it
+		go func() {
+			defer waitGroup.Done()
+			dbCallMutexLock(context.Background(), s, db, results, i, key)
+		}()
 	}
 	waitGroup.Wait()
-	fmt.Printf("Sequential DB calls took: %v\n", time.Since(t1))
+	elapsed = s.clock.Since(t1)
+	s.out.printf("dbCallMutexLock total", elapsed, "DB calls sharing a results store took: %v\n", elapsed)
 
+	stored, err := results.List()
+	if err != nil {
+		s.out.printf("db results", err.Error(), "Error: %v\n", err)
+		return
+	}
+	s.out.printf("db results", stored, "Results stored: %v\n", stored)
+
+	// Fault injection and retry
+	// This time calls fail, hang or run slow as often as the session's failure model says (see --db-error-rate)
+	// Failures worth retrying are tried again after 200ms, 400ms, 800ms, each wait shortened by up to half at random
+	policy := retry.Policy{Attempts: 4, Base: 200 * time.Millisecond, Max: time.Second, Jitter: 0.5, Retryable: dbRetryable}
+	attempts := make([]int, len(demoKeys))
+	errs := make([]error, len(demoKeys))
+	t4 := s.clock.Now()
+	for i, key := range demoKeys {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_, attempts[i], errs[i] = dbCallRetry(context.Background(), s, db, policy, i, key)
+		}()
+	}
+	waitGroup.Wait()
+	elapsed = s.clock.Since(t4)
+	s.out.printf("retry total", elapsed, "DB calls with retries took: %v\n", elapsed)
+
+	var gaveUp []string
+	for i, key := range demoKeys {
+		if errs[i] != nil {
+			gaveUp = append(gaveUp, key)
+			s.out.printf("retry attempts", attempts[i], "%s: failed after %d attempt(s): %v\n", key, attempts[i], errs[i])
+			continue
+		}
+		s.out.printf("retry attempts", attempts[i], "%s: %d attempt(s)\n", key, attempts[i])
+	}
+	s.out.printf("retry failed", gaveUp, "Failed after retries: %v\n", gaveUp)
+}
+
+func channelsSection(s *session) {
 	/*
 
 		Channels
@@ -447,21 +842,23 @@ World!` // backticks for multiline strings
 
 	go channelProcess(channel) // start a go routine to send a value to the channel
 	var chanVar = <-channel    // receive value from channel, blocks until a value is sent to the channel
-	fmt.Println("Value received from channel:", chanVar)
+	s.out.println("channel", chanVar, "Value received from channel:", chanVar)
 
-	go channelProcessLoop(channel) // start a go routine to send multiple values to the channel
-	for v := range channel {       // receive values from channel until it is closed, blocks until a value is sent to the channel
-		fmt.Println("Value received from channel:", v)
+	go channelProcessLoop(s, channel) // start a go routine to send multiple values to the channel
+	for v := range channel {          // receive values from channel until it is closed, blocks until a value is sent to the channel
+		s.out.println("channel", v, "Value received from channel:", v)
 	} // prints as values are received from the channel, fast
 
 	// Buffer channels
 	var bufferChannel = make(chan int, 5)
-	go channelProcessLoop(bufferChannel) // channelProcessLoop function process ends before the receiving loop ends, because the channel has a buffer of 5 and can hold all values sent to it before blocking
+	go channelProcessLoop(s, bufferChannel) // channelProcessLoop function process ends before the receiving loop ends, because the channel has a buffer of 5 and can hold all values sent to it before blocking
 	for v := range bufferChannel {
-		fmt.Println("Value received from buffered channel:", v)
-		time.Sleep(time.Second * 1) // simulate slow processing of received values
+		s.out.println("bufferChannel", v, "Value received from buffered channel:", v)
+		s.clock.Sleep(time.Second * 1) // simulate slow processing of received values
 	}
+}
 
+func genericsSection(s *session) {
 	/*
 
 		Generics
@@ -474,11 +871,28 @@ World!` // backticks for multiline strings
 	*/
 
 	var intSliceGen = []int{1, 2, 3}
-	fmt.Println(sumSlice[int](intSliceGen)) // specify type parameter when calling generic function, but can be inferred by the compiler
+	s.out.show("sumSlice[int]", sumSlice[int](intSliceGen)) // specify type parameter when calling generic function, but can be inferred by the compiler
 	var float32SliceGen = []float32{1.1, 2.2, 3.3}
-	fmt.Println(sumSlice(float32SliceGen)) // type parameter inferred by the compiler
+	s.out.show("sumSlice[float32]", sumSlice(float32SliceGen)) // type parameter inferred by the compiler
 	var float64SliceGen = []float64{1.11, 2.22, 3.33}
-	fmt.Println(sumSlice(float64SliceGen)) // type parameter inferred by the compiler
+	s.out.show("sumSlice[float64]", sumSlice(float64SliceGen)) // type parameter inferred by the compiler
+
+	// stats.Sum is sumSlice with a named constraint, Number lists every numeric type so int64 or uint8 slices work too
+	// The rest of the stats package grows Sum into a summary, one generic function for whole and decimal numbers
+	var fleetMPG = []int{25, 31, 18, 40, 27, 22} // whole miles per gallon from a spec sheet
+	if summary, err := stats.Summarize(fleetMPG); err == nil {
+		s.out.printf("stats.Summarize[int]", summary, "mpg: %v\n", summary)
+	}
+	var fleetRanges = []float64{375, 30, 436, 120.5, 212.25} // computed by MilesLeft
+	if summary, err := stats.Summarize(fleetRanges); err == nil {
+		s.out.printf("stats.Summarize[float64]", summary, "range: %v\n", summary)
+	}
+	if p75, err := stats.Percentile(fleetRanges, 75); err == nil {
+		s.out.printf("stats.Percentile", p75, "75%% of the ranges are below %.2f miles\n", p75)
+	}
+	if _, err := stats.Mean([]float64{}); err != nil {
+		s.out.printf("stats.Mean", err.Error(), "Mean of nothing: Error: %v\n", err)
+	}
 }
 
 /*
@@ -490,8 +904,8 @@ World!` // backticks for multiline strings
 
 */
 
-func printMyName(name string) {
-	fmt.Println("My name is", name)
+func printMyName(s *session, name string) {
+	s.out.println("name", name, "My name is", name)
 }
 
 func intDivision(numerator int, denominator int) (int, int, error) {
@@ -503,6 +917,7 @@ func intDivision(numerator int, denominator int) (int, int, error) {
 	return result, remainder, nil
 }
 
+// timeLoop measures real work, so it always uses the wall clock rather than the session clock
 func timeLoop(slice []int, n int) time.Duration {
 	start := time.Now()
 	for len(slice) < n {
@@ -520,27 +935,187 @@ func squareArray(fl64Value *[5]float64) [5]float64 {
 }
 
 // Go routine function example
-func dbCall(i int) {
-	var delay float32 = rand.Float32() * 2000
-	time.Sleep(time.Duration(delay) * time.Millisecond)
-	fmt.Printf("DB call %d took %f seconds\n", i, delay/1000)
-	waitGroup.Done() // decrement the waitgroup counter when the go routine completes
+// dbPrintLimit is the largest dataset whose DB calls print a line each
+const dbPrintLimit = 20
+
+// dbJob is one DB call for the worker pool, index picks the call's random stream as in dbCall
+type dbJob struct {
+	index int
+	key   string
+}
+
+func dbJobs(keys []string) []dbJob {
+	jobs := make([]dbJob, len(keys))
+	for i, key := range keys {
+		jobs[i] = dbJob{index: i, key: key}
+	}
+	return jobs
+}
+
+// dbFanOut reads every key with dbCall, the way --db-mode asks for, and returns the results in key order
+// along with how long each call took, calls never started once ctx was done are left out of the durations
+func dbFanOut(ctx context.Context, s *session, db store.Store, keys []string) ([]pool.Result[dbJob, string], []time.Duration) {
+	jobs := dbJobs(keys)
+	took := make([]time.Duration, len(jobs)) // each call writes only its own index
+	call := func(ctx context.Context, job dbJob) (string, error) {
+		start := s.clock.Now()
+		defer func() { took[job.index] = s.clock.Since(start) }()
+		if s.db.callDeadline > 0 {
+			// a child context is done when its own deadline passes or when the parent is done, whichever comes first
+			var cancel context.CancelFunc
+			ctx, cancel = clock.WithTimeout(ctx, s.clock, s.db.callDeadline)
+			defer cancel()
+		}
+		return dbCall(ctx, s, db, job.index, job.key)
+	}
+
+	switch s.db.mode {
+	case dbPool:
+		results := pool.New(s.db.workers, call).Run(ctx, jobs)
+		var started []time.Duration
+		for i, r := range results {
+			// jobs still queued when ctx is done are never started, the pool hands back the bare context error
+			if ctx.Err() != nil && r.Err == context.Cause(ctx) {
+				results[i].Err = fmt.Errorf("%w before it started: %w", errDBCancelled, r.Err)
+				continue
+			}
+			started = append(started, took[i])
+		}
+		return results, started
+
+	case dbSequential:
+		results := make([]pool.Result[dbJob, string], len(jobs))
+		started := 0
+		for i, job := range jobs {
+			results[i] = pool.Result[dbJob, string]{Index: i, Job: job}
+			if ctx.Err() != nil { // the deadline passed during an earlier call, the rest are not started
+				results[i].Err = fmt.Errorf("%w before it started: %w", errDBCancelled, context.Cause(ctx))
+				continue
+			}
+			results[i].Value, results[i].Err = call(ctx, job) // each call waits for the one before it
+			started++
+		}
+		return results, took[:started]
+	}
+
+	// dbUnbounded, one go routine per key
+	results := make([]pool.Result[dbJob, string], len(jobs)) // each go routine writes only its own index, so no lock is needed
+	var waitGroup = sync.WaitGroup{}                         // create a waitgroup to wait for all go routines to finish, essentially a counter
+	// Add waitgroup.Add(1) before starting a go routine
+	// Add waitgroup.Wait() to wait for all go routines to finish
+	// Add waitgroup.Done() at the end of the go routine to decrement the counter
+	for i, job := range jobs {
+		waitGroup.Add(1) // increment the waitgroup counter before starting a go routine
+
+		go func() { // concurrent calls, takes less time, use 'go' keyword infront of function
+			defer waitGroup.Done() // decrement the counter when the call returns, defer runs it even if dbCall panics
+			results[i] = pool.Result[dbJob, string]{Index: i, Job: job}
+			results[i].Value, results[i].Err = call(ctx, job)
+		}()
+		// go routines run in the background, main function may exit before they complete, so a waitgroup or sleep may be needed to wait for them to finish
+	}
+	waitGroup.Wait() // wait for all go routines to finish
+	return results, took
 }
 
-func dbCallMutexLock(i int) {
-	var delay float32 = 2000
-	time.Sleep(time.Duration(delay) * time.Millisecond)
-	fmt.Printf("DB call %d took %f seconds\n", i, delay/1000)
+var (
+	errDBCancelled   = errors.New("DB call cancelled") // the call's context was done, errors.Is tells it apart from a failed lookup
+	errDBUnavailable = errors.New("DB unavailable")    // injected by dbFaults, worth retrying
+	errDBTimeout     = errors.New("DB call timed out") // injected by dbFaults, worth retrying
+)
+
+// dbRetryable is the retry policy's test: injected faults are retried, a missing key or a cancelled call is not
+func dbRetryable(err error) bool {
+	return errors.Is(err, errDBUnavailable) || errors.Is(err, errDBTimeout)
+}
 
-	mutex.Lock() // lock access to shared resource
-	// Necessary to prevent threads from writing to the shared resource at the same time
+// dbCall reads one key from db after a delay drawn from --latency, i picks the random stream so the delay is the same every run
+// It gives up as soon as ctx is done, the error then wraps errDBCancelled and the context's cause
+func dbCall(ctx context.Context, s *session, db store.Store, i int, key string) (string, error) {
+	return dbAttempt(ctx, s, db, s.rand(int64(i)), dbFaults{}, i, 0, key) // each call gets its own random source so the delays do not depend on which go routine runs first
+}
 
-	dbResults = append(dbResults, dbData[i]) // simulate storing result in a shared resource
-	mutex.Unlock()                           // lock access to shared resource
-	// Can also use Read/Write mutex for more granular control over read and write access
-	// Can use Rlock() and RUnlock() for read access
+// dbCallRetry is dbCall with the session's failure model, retried with exponential backoff as policy says
+// It returns the value, how many attempts were made and the last attempt's error
+func dbCallRetry(ctx context.Context, s *session, db store.Store, policy retry.Policy, i int, key string) (string, int, error) {
+	rng := s.rand(int64(i)) // one source for every attempt and backoff, so each attempt draws new values
+	var value string
+	attempt := 0
+	attempts, err := retry.Do(ctx, s.clock, rng, policy, func(ctx context.Context) error {
+		attempt++
+		var err error
+		value, err = dbAttempt(ctx, s, db, rng, s.db.faults, i, attempt, key)
+		return err
+	})
+	return value, attempts, err
+}
 
-	waitGroup.Done() // decrement the waitgroup counter when the go routine completes
+// dbAttempt is a single simulated DB call, the session's latency model draws its delay and faults decides whether it fails, hangs or is slow
+// attempt numbers the tries of a retried call in the output, 0 for a call that is not retried
+func dbAttempt(ctx context.Context, s *session, db store.Store, rng *rand.Rand, faults dbFaults, i, attempt int, key string) (string, error) {
+	label := fmt.Sprintf("dbCall %d", i)
+	name := fmt.Sprintf("DB call %d", i)
+	if attempt > 0 {
+		name = fmt.Sprintf("DB call %d attempt %d", i, attempt)
+	}
+
+	delay := s.db.latency.Draw(rng)
+	var fault error
+	if faults != (dbFaults{}) { // the zero model draws nothing more, so calls without faults keep their delays
+		if rng.Float64() < faults.slowRate {
+			delay = time.Duration(float64(delay) * faults.slowFactor)
+		}
+		switch roll := rng.Float64(); {
+		case roll < faults.errorRate:
+			fault = errDBUnavailable
+		case roll < faults.errorRate+faults.timeoutRate:
+			delay, fault = faults.timeout, errDBTimeout // the call hangs until the client stops waiting
+		}
+	}
+
+	start := s.clock.Now()
+	if err := clock.SleepContext(ctx, s.clock, delay); err != nil {
+		err = fmt.Errorf("%w after %v: %w", errDBCancelled, s.clock.Since(start), err)
+		s.dbPrintf(label, err.Error(), "%s: %v\n", name, err)
+		return "", err
+	}
+	if fault != nil {
+		err := fmt.Errorf("%w after %v", fault, delay.Round(time.Millisecond))
+		s.dbPrintf(label, err.Error(), "%s: Error: %v\n", name, err)
+		return "", err
+	}
+	value, err := db.Get(key)
+	if err != nil {
+		s.dbPrintf(label, err.Error(), "%s: Error: %v\n", name, err)
+		return "", err
+	}
+	s.dbPrintf(label, delay, "%s took %f seconds\n", name, delay.Seconds())
+	return value, nil
+}
+
+// dbCallMutexLock copies one key from db to results, many of these run at once and share the results store
+// Nothing is stored when ctx is done before the delay is over
+func dbCallMutexLock(ctx context.Context, s *session, db, results store.Store, i int, key string) error {
+	label := fmt.Sprintf("dbCallMutexLock %d", i)
+	delay := s.db.lockLatency.Draw(s.rand(int64(i)))
+	start := s.clock.Now()
+	if err := clock.SleepContext(ctx, s.clock, delay); err != nil {
+		err = fmt.Errorf("%w after %v: %w", errDBCancelled, s.clock.Since(start), err)
+		s.dbPrintf(label, err.Error(), "DB call %d: %v\n", i, err)
+		return err
+	}
+	s.dbPrintf(label, delay, "DB call %d took %f seconds\n", i, delay.Seconds())
+
+	value, err := db.Get(key)
+	if err == nil {
+		// simulate storing result in a shared resource, Put locks the store so two go routines never write at the same time
+		// (store.Memory uses a Read/Write mutex: Get takes the shared RLock, Put the exclusive Lock)
+		err = results.Put(key, value)
+	}
+	if err != nil {
+		s.dbPrintf(label, err.Error(), "DB call %d: Error: %v\n", i, err)
+	}
+	return err
 }
 
 // Go routine for channels example
@@ -549,13 +1124,13 @@ func channelProcess(ch chan int) {
 	ch <- 42 // send value to channel
 }
 
-func channelProcessLoop(ch chan int) {
+func channelProcessLoop(s *session, ch chan int) {
 	defer close(ch) // closes the channel when the function exits
 	// keyword defer delays the execution of a function until the surrounding function returns, last statement to be executed
 	for i := 0; i < 5; i++ {
 		ch <- i // send value to channel
 	}
-	fmt.Println("Channel sender done sending values")
+	s.out.println("sender", "done", "Channel sender done sending values")
 	// close(ch) // can also close the channel here, but defer is more reliable
 	// closing channel necessary to prevent deadlock when ranging over the channel in the receiving go routine
 }
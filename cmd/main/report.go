@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+)
+
+/*
+
+	Output
+
+	Sections never print directly, they hand every value to a reporter
+	In text mode the reporter prints exactly what the lesson printed before
+	In json and ndjson modes each value becomes a record that tooling can diff or post-process
+
+*/
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"   // one JSON array holding every record, written when the run finishes
+	formatNDJSON = "ndjson" // one JSON record per line, written as soon as it is reported
+)
+
+// record is a single structured value reported by a section
+type record struct {
+	Section string        `json:"section"`
+	Label   string        `json:"label"`
+	Value   any           `json:"value"`
+	Type    string        `json:"type"`
+	Text    string        `json:"text,omitempty"`        // the line text mode would have printed
+	Elapsed time.Duration `json:"elapsed_ns"`            // time since the section started
+	Total   bool          `json:"section_end,omitempty"` // marks the record closing a section
+}
+
+type reporter struct {
+	mu      sync.Mutex // sections report from go routines, so every write is locked
+	w       io.Writer
+	format  string
+	section string
+	clock   clock.Clock // elapsed times follow the session clock, so they are virtual with --fast
+	start   time.Time
+	records []record // only kept in json mode, written out by flush
+}
+
+func newReporter(w io.Writer, format string, clk clock.Clock) (*reporter, error) {
+	switch format {
+	case formatText, formatJSON, formatNDJSON:
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want %s, %s or %s)", format, formatText, formatJSON, formatNDJSON)
+	}
+	return &reporter{w: w, format: format, clock: clk}, nil
+}
+
+// begin starts a section, printing its banner in text mode
+func (r *reporter) begin(sec section) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.section = sec.name
+	r.start = r.clock.Now()
+	if r.format == formatText {
+		fmt.Fprintln(r.w, strings.Repeat("-", 50))
+		fmt.Fprintln(r.w, sec.title)
+		fmt.Fprintln(r.w, strings.Repeat("-", 50))
+	}
+}
+
+// end closes a section with a record holding its total run time
+func (r *reporter) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.format == formatText {
+		return
+	}
+	elapsed := r.clock.Since(r.start)
+	r.emit(record{Section: r.section, Label: "section elapsed", Value: elapsed, Type: "time.Duration", Elapsed: elapsed, Total: true})
+}
+
+// show reports a single value, printed the way fmt.Println(v) prints it
+func (r *reporter) show(label string, v any) {
+	r.report(label, v, fmt.Sprintln(v))
+}
+
+// println reports value v, printing the operands a the way fmt.Println does
+func (r *reporter) println(label string, v any, a ...any) {
+	r.report(label, v, fmt.Sprintln(a...))
+}
+
+// printf reports value v, printing the operands a with format the way fmt.Printf does
+func (r *reporter) printf(label string, v any, format string, a ...any) {
+	r.report(label, v, fmt.Sprintf(format, a...))
+}
+
+// lenCap reports the length and capacity of a slice
+func (r *reporter) lenCap(label string, length, capacity int) {
+	r.report(label, map[string]int{"length": length, "capacity": capacity}, fmt.Sprintf("Length: %d, Capacity: %d\n", length, capacity))
+}
+
+func (r *reporter) report(label string, v any, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.format == formatText {
+		io.WriteString(r.w, text)
+		return
+	}
+	r.emit(record{
+		Section: r.section,
+		Label:   label,
+		Value:   jsonValue(v),
+		Type:    fmt.Sprintf("%T", v),
+		Text:    strings.TrimSuffix(text, "\n"),
+		Elapsed: r.clock.Since(r.start),
+	})
+}
+
+// emit must be called with r.mu held
+func (r *reporter) emit(rec record) {
+	if r.format == formatJSON {
+		r.records = append(r.records, rec)
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		line, _ = json.Marshal(record{Section: rec.Section, Label: rec.Label, Value: err.Error(), Type: "error"})
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+// flush writes the buffered records in json mode, other modes have already written everything
+func (r *reporter) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.format != formatJSON {
+		return nil
+	}
+	records := r.records
+	if records == nil {
+		records = []record{} // print [] rather than null when nothing ran
+	}
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// jsonValue returns v if encoding/json can represent it, otherwise its printed form
+// Other pointers are printed so the record shows the memory address the lesson is talking about,
+// and structs with only unexported fields would otherwise encode as {}
+func jsonValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct {
+		return jsonValue(rv.Elem().Interface()) // a pointer to a struct is reported as the struct it points to
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if rv.Kind() == reflect.Struct && string(b) == "{}" && rv.NumField() > 0 {
+		return fmt.Sprintf("%+v", v)
+	}
+	return v
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+	"github.com/donnebaldemeca/GoBasics/latency"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+/*
+
+	Sections
+
+	Each lesson is registered as a named section so it can be listed, run on its own or skipped
+	Sections run in the order they are registered
+
+*/
+
+type section struct {
+	name  string // short name used on the command line, e.g. --section channels
+	title string // banner printed before the section runs
+	run   func(s *session)
+
+	concurrent bool // prints from go routines, so the order of its lines can change between runs
+}
+
+var sections = []section{
+	{name: "variables", title: "Variables and Data Types", run: variablesSection},
+	{name: "data-structures", title: "Data Structures", run: dataStructuresSection},
+	{name: "performance", title: "Performance Test", run: performanceSection},
+	{name: "strings", title: "Strings, Runes, and Bytes", run: stringsSection},
+	{name: "structs", title: "Structs, Interfaces, and Methods", run: structsSection},
+	{name: "factories", title: "Engine Factories", run: factoriesSection},
+	{name: "lifecycle", title: "Refuel and Recharge", run: lifecycleSection},
+	{name: "trips", title: "Trip Planning", run: tripsSection},
+	{name: "costs", title: "Energy Cost and Emissions", run: costsSection},
+	{name: "sorting", title: "Sorting and Filtering", run: sortingSection},
+	{name: "http", title: "HTTP API", run: httpSection},
+	{name: "rpc", title: "JSON-RPC", run: rpcSection},
+	{name: "pointers", title: "Pointers and Memory Management", run: pointersSection},
+	{name: "goroutines", title: "Go Routines", run: goroutinesSection, concurrent: true},
+	{name: "channels", title: "Channels", run: channelsSection, concurrent: true},
+	{name: "generics", title: "Generics", run: genericsSection},
+}
+
+func findSection(name string) (section, bool) {
+	for _, s := range sections {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return section{}, false
+}
+
+// nameList is a flag.Value that collects section names, accepting both repeated flags and comma separated lists
+type nameList []string
+
+func (n *nameList) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *nameList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := findSection(name); !ok {
+			return fmt.Errorf("unknown section %q (use --list to see all sections)", name)
+		}
+		*n = append(*n, name)
+	}
+	return nil
+}
+
+// selectSections returns the sections to run, in registration order
+// With no --section names every section is selected, then --skip removes sections from the selection
+func selectSections(only, skip nameList) []section {
+	var selected []section
+	for _, s := range sections {
+		if len(only) > 0 && !contains(only, s.name) {
+			continue
+		}
+		if contains(skip, s.name) {
+			continue
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fleet" {
+		os.Exit(runFleet(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	var only, skip nameList
+	list := flag.Bool("list", false, "list the available sections and exit")
+	all := flag.Bool("all", false, "run every section (the default when no --section is given)")
+	flag.Var(&only, "section", "run only the named section, may be repeated or comma separated")
+	flag.Var(&skip, "skip", "skip the named section, may be repeated or comma separated")
+	format := flag.String("output", formatText, "output format: text, json or ndjson")
+	seed := flag.Int64("seed", 1, "seed every random source and sort map output so runs repeat exactly")
+	golden := flag.Bool("golden", false, "compare each section's output against its golden file instead of printing it")
+	updateGolden := flag.Bool("update-golden", false, "rewrite the golden files from the current output")
+	dir := flag.String("golden-dir", goldenDir, "directory holding the golden files")
+	units := flag.String("units", "imperial", "print distances and amounts in imperial or metric units")
+	fast := flag.Bool("fast", false, "run sleeps on a virtual clock so they finish instantly while still reporting the simulated durations")
+	dbMode := flag.String("db-mode", defaultDBConfig.mode, "how the goroutines section runs its DB calls: unbounded, pool or sequential")
+	workers := flag.Int("workers", defaultDBConfig.workers, "worker go routines for --db-mode pool")
+	records := flag.Int("db-records", defaultDBConfig.records, "records in the goroutines section's simulated database")
+	deadline := flag.Duration("db-deadline", defaultDBConfig.deadline, "deadline of the goroutines section's whole DB fan-out, 0 for none")
+	callDeadline := flag.Duration("db-call-deadline", defaultDBConfig.callDeadline, "deadline of each DB call in the fan-out, 0 for none")
+	faults := defaultDBConfig.faults
+	flag.Float64Var(&faults.errorRate, "db-error-rate", faults.errorRate, "share of DB calls in the goroutines section's retry demo that fail, from 0 to 1; the fan-out, pool and lock demos never fail")
+	flag.Float64Var(&faults.timeoutRate, "db-timeout-rate", faults.timeoutRate, "share of DB calls in the retry demo that hang until they time out, from 0 to 1")
+	flag.Float64Var(&faults.slowRate, "db-slow-rate", faults.slowRate, "share of DB calls in the retry demo that run several times slower, from 0 to 1")
+	latencySpec := flag.String("latency", defaultDBConfig.latency.String(), "delay model of dbCall: fixed:D, uniform:MIN,MAX, normal:MEAN,STDDEV, exponential:MEAN, lognormal:MEDIAN,SIGMA or replay:FILE")
+	lockLatencySpec := flag.String("lock-latency", defaultDBConfig.lockLatency.String(), "delay model of dbCallMutexLock, as for --latency")
+	flag.Parse()
+
+	deterministic := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			deterministic = true
+		}
+	})
+
+	if *list {
+		for _, s := range sections {
+			fmt.Printf("%-16s %s\n", s.name, s.title)
+		}
+		return
+	}
+	if *all && len(only) > 0 {
+		fmt.Fprintln(os.Stderr, "--all and --section cannot be used together")
+		os.Exit(2)
+	}
+
+	selected := selectSections(only, skip) // --all only spells out the default, it was rejected above next to --section
+	if *golden || *updateGolden {
+		ok, err := runGolden(os.Stdout, *dir, selected, *seed, *updateGolden)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var clk clock.Clock = clock.Real{}
+	if *fast {
+		clk = clock.NewAuto(time.Now())
+	}
+
+	out, err := newReporter(os.Stdout, *format, clk)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	s := newSession(out, clk, *seed, deterministic)
+	if s.units, err = vehicle.ParseUnitSystem(*units); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if s.db.mode, err = parseDBMode(*dbMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *workers < 1 || *records < 1 {
+		fmt.Fprintln(os.Stderr, "--workers and --db-records must be at least 1")
+		os.Exit(2)
+	}
+	if *fast && *records > dbFastRecordLimit {
+		fmt.Fprintf(os.Stderr, "--fast handles at most %d --db-records, its virtual clock advances one deadline at a time; drop --fast for larger datasets\n", dbFastRecordLimit)
+		os.Exit(2)
+	}
+	if *deadline < 0 || *callDeadline < 0 {
+		fmt.Fprintln(os.Stderr, "--db-deadline and --db-call-deadline cannot be negative")
+		os.Exit(2)
+	}
+	if err := faults.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	s.db.workers, s.db.records, s.db.faults = *workers, *records, faults
+	s.db.deadline, s.db.callDeadline = *deadline, *callDeadline
+	if s.db.latency, err = latency.Parse(*latencySpec); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if s.db.lockLatency, err = latency.Parse(*lockLatencySpec); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, sec := range selected {
+		out.begin(sec)
+		sec.run(s)
+		out.end()
+	}
+	if err := out.flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+	"github.com/donnebaldemeca/GoBasics/latency"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// session is handed to every section and holds the state shared by a run
+type session struct {
+	out           *reporter
+	clock         clock.Clock // every sleep and simulated duration goes through this clock
+	units         vehicle.UnitSystem
+	seed          int64 // base seed every random source is derived from
+	deterministic bool  // set by --seed, makes every run print the same thing
+	db            dbConfig
+}
+
+// dbConfig is how the goroutines section fans out its DB calls, set by --db-mode, --workers and --db-records
+type dbConfig struct {
+	mode    string
+	workers int // go routines in the pool, only used by dbPool
+	records int // rows in the simulated database
+	faults  dbFaults
+
+	deadline     time.Duration // the whole fan-out's deadline, set by --db-deadline, 0 for none
+	callDeadline time.Duration // each fan-out call's deadline, set by --db-call-deadline, 0 for none
+
+	latency     latency.Model // delay of each dbCall, set by --latency
+	lockLatency latency.Model // delay of each dbCallMutexLock, set by --lock-latency
+}
+
+// dbFaults is the failure model of a simulated DB call, the zero value never fails
+type dbFaults struct {
+	errorRate   float64 // share of calls that fail with errDBUnavailable
+	timeoutRate float64 // share of calls that hang for timeout and then fail with errDBTimeout
+	timeout     time.Duration
+	slowRate    float64 // share of calls that take slowFactor times as long, the slow tail
+	slowFactor  float64
+}
+
+const (
+	dbUnbounded  = "unbounded"  // one go routine per call
+	dbPool       = "pool"       // a fixed number of worker go routines
+	dbSequential = "sequential" // one call after the other
+)
+
+var defaultDBConfig = dbConfig{
+	mode:    dbUnbounded,
+	workers: 4,
+	records: 5,
+
+	deadline:     10 * time.Second,
+	callDeadline: 1500 * time.Millisecond, // cuts off the slowest quarter of the default 0s to 2s calls
+
+	latency:     latency.Uniform{Min: 0, Max: 2 * time.Second},
+	lockLatency: latency.Fixed{D: 2 * time.Second},
+	faults:      dbFaults{errorRate: 0.3, timeoutRate: 0.1, timeout: 3 * time.Second, slowRate: 0.1, slowFactor: 5},
+}
+
+// dbFastRecordLimit is the largest dataset --fast accepts
+// The virtual clock waits about 2ms of real time before each jump to the next deadline, and every DB call
+// has deadlines of its own, so a hundred thousand calls would take minutes instead of the seconds they take on the wall clock
+const dbFastRecordLimit = 2000
+
+func parseDBMode(mode string) (string, error) {
+	switch mode {
+	case dbUnbounded, dbPool, dbSequential:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown DB mode %q (want unbounded, pool or sequential)", mode)
+}
+
+func (f dbFaults) validate() error {
+	for _, rate := range []float64{f.errorRate, f.timeoutRate, f.slowRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("DB fault rate %g is outside 0 to 1", rate)
+		}
+	}
+	if f.errorRate+f.timeoutRate > 1 {
+		return fmt.Errorf("DB error rate %g and timeout rate %g add up to more than 1", f.errorRate, f.timeoutRate)
+	}
+	return nil
+}
+
+func (c dbConfig) describe() string {
+	switch c.mode {
+	case dbPool:
+		return fmt.Sprintf("pool of %d workers", c.workers)
+	case dbSequential:
+		return "sequential"
+	}
+	return "one go routine per call"
+}
+
+func (c dbConfig) describeDeadlines() string {
+	deadline, callDeadline := "no deadline", "none per call"
+	if c.deadline > 0 {
+		deadline = fmt.Sprintf("deadline %v", c.deadline)
+	}
+	if c.callDeadline > 0 {
+		callDeadline = fmt.Sprintf("%v per call", c.callDeadline)
+	}
+	return deadline + ", " + callDeadline
+}
+
+// byIDNumber orders the generated keys id1, id2, ..., id10 by their number
+// They have no leading zeros, so a shorter key always has the smaller number
+func byIDNumber(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b))
+}
+
+func newSession(out *reporter, clk clock.Clock, seed int64, deterministic bool) *session {
+	if !deterministic {
+		seed = rand.Int63() // the global source is randomly seeded, so runs differ as before
+	}
+	return &session{out: out, clock: clk, seed: seed, deterministic: deterministic, db: defaultDBConfig}
+}
+
+// dbPrintf prints a line for one DB call, datasets larger than dbPrintLimit skip them so the output stays readable
+func (s *session) dbPrintf(label string, v any, format string, a ...any) {
+	if s.db.records <= dbPrintLimit {
+		s.out.printf(label, v, format, a...)
+	}
+}
+
+// rand returns a random source for one stream of values, e.g. one DB call
+// Deriving a source per stream keeps the values the same no matter which go routine asks first
+func (s *session) rand(stream int64) *rand.Rand {
+	return rand.New(rand.NewSource(s.seed + stream))
+}
+
+// mapKeys returns the keys of m, sorted in deterministic mode and in Go's random map order otherwise
+func mapKeys[K cmp.Ordered, V any](s *session, m map[K]V) []K {
+	keys := slices.Collect(maps.Keys(m))
+	if s.deterministic {
+		slices.Sort(keys)
+	}
+	return keys
+}
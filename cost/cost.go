@@ -0,0 +1,228 @@
+// Package cost works out what a trip costs and how much CO2 it emits, for any vehicle.Engine
+package cost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+var ErrNoPrice = errors.New("cost: no price for energy source")
+
+// Tariff is an electricity price for part of the day, from hour From up to but not including hour To
+// A tariff that wraps past midnight has From greater than To, e.g. 22 to 6 for night charging
+type Tariff struct {
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	PerKWh float64 `json:"per_kwh"`
+}
+
+func (t Tariff) covers(hour int) bool {
+	if t.From <= t.To {
+		return t.From <= hour && hour < t.To
+	}
+	return hour >= t.From || hour < t.To
+}
+
+// Prices is a price table, amounts are in whatever currency the table is written in
+type Prices struct {
+	PerGallon float64  `json:"per_gallon"`
+	PerLitre  float64  `json:"per_litre"` // used for gasoline when PerGallon is 0
+	PerKWh    float64  `json:"per_kwh"`   // used when no tariff covers the hour
+	Tariffs   []Tariff `json:"tariffs,omitempty"`
+	PerKg     float64  `json:"per_kg"` // hydrogen
+}
+
+// DefaultPrices are rough US averages in dollars
+var DefaultPrices = Prices{
+	PerGallon: 3.50,
+	PerKWh:    0.17,
+	Tariffs:   []Tariff{{From: 22, To: 6, PerKWh: 0.10}},
+	PerKg:     30,
+}
+
+// UnitPrice is the price of one unit of source (a gallon, a kWh or a kg) at time at
+func (p Prices) UnitPrice(source vehicle.Source, at time.Time) (float64, error) {
+	switch source {
+	case vehicle.Gasoline:
+		if p.PerGallon == 0 && p.PerLitre != 0 {
+			return p.PerLitre * vehicle.LitresPerGallon, nil
+		}
+		return p.PerGallon, nil
+	case vehicle.Electricity:
+		for _, t := range p.Tariffs {
+			if t.covers(at.Hour()) {
+				return t.PerKWh, nil
+			}
+		}
+		return p.PerKWh, nil
+	case vehicle.Hydrogen:
+		return p.PerKg, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrNoPrice, source)
+}
+
+// Factors are emission factors in kg of CO2 per unit of each source
+type Factors map[vehicle.Source]float64
+
+// DefaultFactors: burning a US gallon of gasoline, the average US grid per kWh, and hydrogen made from natural gas per kg
+var DefaultFactors = Factors{
+	vehicle.Gasoline:    8.89,
+	vehicle.Electricity: 0.37,
+	vehicle.Hydrogen:    10,
+}
+
+// ParseFactors reads factors from a JSON object such as {"electricity": 0.2}
+// Sources the object leaves out keep their DefaultFactors value
+func ParseFactors(data []byte) (Factors, error) {
+	var read map[vehicle.Source]float64
+	if err := json.Unmarshal(data, &read); err != nil {
+		return nil, err
+	}
+	factors := maps.Clone(DefaultFactors)
+	for source, kg := range read {
+		if source.Unit() == "" {
+			return nil, fmt.Errorf("cost: unknown energy source %q (want gasoline, electricity or hydrogen)", source)
+		}
+		if kg < 0 || math.IsNaN(kg) || math.IsInf(kg, 0) {
+			return nil, fmt.Errorf("cost: emission factor for %s must be a number of at least 0, got %g", source, kg)
+		}
+		factors[source] = kg
+	}
+	return factors, nil
+}
+
+// Line is the energy, cost and CO2 of one source in an estimate
+type Line struct {
+	vehicle.Draw
+	UnitPrice float64 `json:"unit_price"`
+	Cost      float64 `json:"cost"`
+	CO2Kg     float64 `json:"co2_kg"`
+}
+
+// Estimate is the energy a trip consumes, what it costs and the CO2 it emits
+type Estimate struct {
+	Miles float64 `json:"miles"`
+	Lines []Line  `json:"lines"`
+	Cost  float64 `json:"cost"`
+	CO2Kg float64 `json:"co2_kg"`
+}
+
+// Trip estimates a trip of miles for e, paying for energy at time at
+// The part of the trip the engine has range for is split by Draw, so a hybrid uses its own drain order,
+// and any miles beyond the range are bought at the engine's EnergyFor
+func Trip(e vehicle.Engine, miles float64, prices Prices, factors Factors, at time.Time) (Estimate, error) {
+	if err := vehicle.CheckTrip(miles); err != nil {
+		return Estimate{}, err
+	}
+	draws, err := vehicle.Sources(e, miles)
+	if err != nil {
+		return Estimate{}, err
+	}
+	covered := 0.0
+	for _, d := range draws {
+		covered += d.Miles
+	}
+	if covered < miles {
+		refiller, ok := e.(vehicle.Refiller)
+		if !ok {
+			return Estimate{}, fmt.Errorf("cost: %T cannot cover the %g miles beyond its range", e, miles-covered)
+		}
+		more, err := refiller.EnergyFor(miles - covered)
+		if err != nil {
+			return Estimate{}, err
+		}
+		draws = append(draws, more...)
+	}
+
+	est := Estimate{Miles: miles}
+	for _, d := range merge(draws) {
+		price, err := prices.UnitPrice(d.Source, at)
+		if err != nil {
+			return Estimate{}, err
+		}
+		line := Line{Draw: d, UnitPrice: price, Cost: d.Amount * price, CO2Kg: d.Amount * factors[d.Source]}
+		est.Lines = append(est.Lines, line)
+		est.Cost += line.Cost
+		est.CO2Kg += line.CO2Kg
+	}
+	return est, nil
+}
+
+// merge adds up draws of the same source, keeping the order each source first appears in
+func merge(draws []vehicle.Draw) []vehicle.Draw {
+	var merged []vehicle.Draw
+	for _, d := range draws {
+		i := slices.IndexFunc(merged, func(m vehicle.Draw) bool { return m.Source == d.Source })
+		if i < 0 {
+			merged = append(merged, d)
+			continue
+		}
+		merged[i].Miles += d.Miles
+		merged[i].Amount += d.Amount
+	}
+	return merged
+}
+
+// Row is one engine in a fleet comparison
+type Row struct {
+	Name     string   `json:"name"`
+	Estimate Estimate `json:"estimate"`
+	Err      error    `json:"-"` // set instead of Estimate when the engine could not be estimated
+}
+
+// Compare estimates the same trip for every engine, keyed by a name used in the table
+func Compare(names []string, engines []vehicle.Engine, miles float64, prices Prices, factors Factors, at time.Time) []Row {
+	rows := make([]Row, len(engines))
+	for i, e := range engines {
+		est, err := Trip(e, miles, prices, factors, at)
+		rows[i] = Row{Name: names[i], Estimate: est, Err: err}
+	}
+	return rows
+}
+
+// WriteTable prints rows as an aligned table, cheapest trip first
+func WriteTable(w io.Writer, rows []Row, u vehicle.UnitSystem) error {
+	rows = slices.Clone(rows)
+	slices.SortStableFunc(rows, func(a, b Row) int {
+		if (a.Err == nil) != (b.Err == nil) {
+			if a.Err == nil {
+				return -1
+			}
+			return 1
+		}
+		switch {
+		case a.Estimate.Cost < b.Estimate.Cost:
+			return -1
+		case a.Estimate.Cost > b.Estimate.Cost:
+			return 1
+		}
+		return 0
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tTRIP\tENERGY\tCOST\tCO2")
+	for _, row := range rows {
+		if row.Err != nil {
+			fmt.Fprintf(tw, "%s\t\terror: %v\t\t\n", row.Name, row.Err)
+			continue
+		}
+		energy := ""
+		for i, line := range row.Estimate.Lines {
+			if i > 0 {
+				energy += " + "
+			}
+			energy += vehicle.FormatAmount(line.Amount, line.Source, u)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%.1f kg\n", row.Name, vehicle.FormatDistance(row.Estimate.Miles, u), energy, row.Estimate.Cost, row.Estimate.CO2Kg)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,96 @@
+package cost
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+var noon = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// close enough for money and kilograms worked out in floating point
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestTrip(t *testing.T) {
+	gas := vehicle.GasEngine{MPG: 25, Gallons: 4} // 100 miles of range
+	est, err := Trip(gas, 50, DefaultPrices, DefaultFactors, noon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(est.Lines) != 1 || !near(est.Lines[0].Amount, 2) {
+		t.Fatalf("lines %+v, want 2 gallons", est.Lines)
+	}
+	if !near(est.Cost, 7) || !near(est.CO2Kg, 2*8.89) {
+		t.Errorf("cost %v, CO2 %v kg, want 7 and %v", est.Cost, est.CO2Kg, 2*8.89)
+	}
+
+	// Miles beyond the range are bought at the engine's efficiency as well
+	est, err = Trip(gas, 150, DefaultPrices, DefaultFactors, noon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(est.Lines) != 1 || !near(est.Lines[0].Amount, 6) || !near(est.Lines[0].Miles, 150) {
+		t.Errorf("lines %+v, want 6 gallons for 150 miles", est.Lines)
+	}
+}
+
+func TestTripBadMiles(t *testing.T) {
+	gas := vehicle.GasEngine{MPG: 25, Gallons: 4}
+	tests := []struct {
+		name  string
+		miles float64
+		want  error
+	}{
+		{"negative", -1, vehicle.ErrNegativeTrip},
+		{"NaN", math.NaN(), vehicle.ErrInvalidTrip},
+		{"+Inf", math.Inf(1), vehicle.ErrInvalidTrip},
+		{"-Inf", math.Inf(-1), vehicle.ErrInvalidTrip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if est, err := Trip(gas, tt.miles, DefaultPrices, DefaultFactors, noon); !errors.Is(err, tt.want) {
+				t.Errorf("Trip = %+v, %v, want %v", est, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitPriceTariffs(t *testing.T) {
+	tests := []struct {
+		hour int
+		want float64
+	}{
+		{12, 0.17}, {21, 0.17}, {22, 0.10}, {2, 0.10}, {6, 0.17},
+	}
+	for _, tt := range tests {
+		at := time.Date(2024, 1, 1, tt.hour, 30, 0, 0, time.UTC)
+		if got, err := DefaultPrices.UnitPrice(vehicle.Electricity, at); err != nil || got != tt.want {
+			t.Errorf("price at %02d:30 = %v, %v, want %v", tt.hour, got, err, tt.want)
+		}
+	}
+	litres := Prices{PerLitre: 1}
+	if got, _ := litres.UnitPrice(vehicle.Gasoline, noon); !near(got, vehicle.LitresPerGallon) {
+		t.Errorf("per litre price gives %v a gallon, want %v", got, vehicle.LitresPerGallon)
+	}
+}
+
+func TestParseFactors(t *testing.T) {
+	f, err := ParseFactors([]byte(`{"electricity": 0.2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f[vehicle.Electricity] != 0.2 || f[vehicle.Gasoline] != DefaultFactors[vehicle.Gasoline] {
+		t.Errorf("factors %v, want electricity 0.2 and the default for the rest", f)
+	}
+	if DefaultFactors[vehicle.Electricity] != 0.37 {
+		t.Error("ParseFactors changed DefaultFactors")
+	}
+	for _, bad := range []string{`{"diesel": 1}`, `{"gasoline": -1}`, `{"gasoline": "x"}`, `[`} {
+		if _, err := ParseFactors([]byte(bad)); err == nil {
+			t.Errorf("ParseFactors(%s) accepted it", bad)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package fleet keeps track of engines per owner and saves them between runs
+package fleet
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+var (
+	ErrNoOwner       = errors.New("fleet: no such owner")
+	ErrNoEngine      = errors.New("fleet: no such engine")
+	ErrOwnerConflict = errors.New("fleet: owner ID already belongs to someone else")
+	ErrNoID          = errors.New("fleet: owner has no ID")
+)
+
+// allocateAttempts bounds how often NewOwner asks an allocator for another ID when one is taken
+const allocateAttempts = 16
+
+// Entry is one owner and the engines they own
+type Entry struct {
+	Owner   vehicle.EngineOwner
+	Engines []vehicle.Engine
+}
+
+// Registry stores engines per owner ID, it is safe to use from several go routines
+type Registry struct {
+	mu     sync.RWMutex
+	owners map[vehicle.OwnerID]*Entry
+}
+
+func New() *Registry {
+	return &Registry{owners: make(map[vehicle.OwnerID]*Entry)}
+}
+
+// NewOwner registers an owner without engines, under the first ID from alloc that no other owner uses
+func (r *Registry) NewOwner(name string, alloc vehicle.IDAllocator) (vehicle.EngineOwner, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for range allocateAttempts {
+		id, err := alloc.NextID()
+		if err != nil {
+			return vehicle.EngineOwner{}, err
+		}
+		owner := vehicle.EngineOwner{Name: name, OwnerID: id}
+		if entry, err := r.owner(owner); errors.Is(err, ErrOwnerConflict) || entry != nil {
+			continue // taken, e.g. a sequential allocator that started below IDs loaded from a file
+		} else if err != nil {
+			return vehicle.EngineOwner{}, err
+		}
+		r.owners[id] = &Entry{Owner: owner}
+		return owner, nil
+	}
+	return vehicle.EngineOwner{}, fmt.Errorf("%w: %d IDs in a row were taken", ErrOwnerConflict, allocateAttempts)
+}
+
+// AddOwner registers owner without engines, checking the ID the same way Add does
+// An owner already registered under the same ID and name is left as it is
+func (r *Registry) AddOwner(owner vehicle.EngineOwner) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.owner(owner)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		r.owners[owner.OwnerID] = &Entry{Owner: owner}
+	}
+	return nil
+}
+
+// Add gives an engine to owner, creating the owner if the ID is new, and returns the engine's index
+// Two owners cannot share an ID: adding with a known ID and a different name is an error,
+// and so is an ID whose number or UUID alone already belongs to another owner
+func (r *Registry) Add(owner vehicle.EngineOwner, e vehicle.Engine) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.owner(owner)
+	if err != nil {
+		return 0, err
+	}
+	if entry == nil {
+		entry = &Entry{Owner: owner}
+		r.owners[owner.OwnerID] = entry
+	}
+	entry.Engines = append(entry.Engines, e)
+	return len(entry.Engines) - 1, nil
+}
+
+// NextSequentialID returns an allocator that continues after the highest numeric ID in the registry
+func (r *Registry) NextSequentialID() *vehicle.SequentialIDs {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var highest uint64
+	for id := range r.owners {
+		highest = max(highest, id.ID)
+	}
+	return vehicle.NewSequentialIDs(highest)
+}
+
+// owner returns the entry registered for owner, or nil if the ID is free
+// It must be called with r.mu held
+func (r *Registry) owner(owner vehicle.EngineOwner) (*Entry, error) {
+	if owner.IsZero() {
+		return nil, fmt.Errorf("%w: %s", ErrNoID, owner.Name)
+	}
+	if entry, ok := r.owners[owner.OwnerID]; ok {
+		if entry.Owner.Name != owner.Name {
+			return nil, fmt.Errorf("%w: ID %v is %s, not %s", ErrOwnerConflict, owner.OwnerID, entry.Owner.Name, owner.Name)
+		}
+		return entry, nil
+	}
+	for id, entry := range r.owners {
+		if owner.ID != 0 && id.ID == owner.ID || owner.UUID != "" && id.UUID == owner.UUID {
+			return nil, fmt.Errorf("%w: ID %v overlaps %v", ErrOwnerConflict, owner.OwnerID, entry.Owner)
+		}
+	}
+	return nil, nil
+}
+
+// Update replaces the engine at index for the owner with id
+func (r *Registry) Update(id vehicle.OwnerID, index int, e vehicle.Engine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.entry(id, index)
+	if err != nil {
+		return err
+	}
+	entry.Engines[index] = e
+	return nil
+}
+
+// Remove deletes the engine at index for the owner with id, the owner stays registered until RemoveOwner
+func (r *Registry) Remove(id vehicle.OwnerID, index int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.entry(id, index)
+	if err != nil {
+		return err
+	}
+	entry.Engines = slices.Delete(entry.Engines, index, index+1)
+	return nil
+}
+
+// RemoveOwner deletes an owner and all of their engines
+func (r *Registry) RemoveOwner(id vehicle.OwnerID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.owners[id]; !ok {
+		return fmt.Errorf("%w: ID %v", ErrNoOwner, id)
+	}
+	delete(r.owners, id)
+	return nil
+}
+
+// Get returns a copy of the entry for id
+func (r *Registry) Get(id vehicle.OwnerID) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.owners[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return Entry{Owner: entry.Owner, Engines: slices.Clone(entry.Engines)}, true
+}
+
+// List returns a copy of every entry, ordered by owner ID
+func (r *Registry) List() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]Entry, 0, len(r.owners))
+	for _, entry := range r.owners {
+		entries = append(entries, Entry{Owner: entry.Owner, Engines: slices.Clone(entry.Engines)})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return compareIDs(a.Owner.OwnerID, b.Owner.OwnerID) })
+	return entries
+}
+
+func compareIDs(a, b vehicle.OwnerID) int {
+	if c := cmp.Compare(a.ID, b.ID); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.UUID, b.UUID)
+}
+
+// entry must be called with r.mu held
+func (r *Registry) entry(id vehicle.OwnerID, index int) (*Entry, error) {
+	entry, ok := r.owners[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: ID %v", ErrNoOwner, id)
+	}
+	if index < 0 || index >= len(entry.Engines) {
+		return nil, fmt.Errorf("%w: owner %v has %d engines, no index %d", ErrNoEngine, id, len(entry.Engines), index)
+	}
+	return entry, nil
+}
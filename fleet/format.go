@@ -0,0 +1,749 @@
+package fleet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+/*
+
+	File formats
+
+	json  {"owners":[{"name":..., "id":..., "engines":[{"type":"gas", ...}]}]}, the "type" field says which engine each object is
+	csv   one engine per row, owner_name,owner_id,owner_uuid,type followed by a column per engine field
+	      nested fields such as the engine's owner are flattened to owner.name, owner.id
+	yaml  a small subset of YAML, nested blocks of "key: value" lines and "- " list items
+	gob   encoding/gob, compact but only readable from Go
+
+	Every format holds the same information, so a fleet survives any chain of exports and imports unchanged
+
+*/
+
+var ErrMalformed = errors.New("fleet: malformed file")
+
+// Format names a file format for Import and Export
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatYAML Format = "yaml"
+	FormatGob  Format = "gob"
+)
+
+// ParseFormat reads a format name, "yml" is accepted for yaml
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSON, FormatCSV, FormatYAML, FormatGob:
+		return f, nil
+	case "yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("fleet: unknown format %q (want json, csv, yaml or gob)", s)
+}
+
+// FormatOf picks the format from a file's extension, anything unknown is JSON
+func FormatOf(path string) Format {
+	if f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), ".")); err == nil {
+		return f
+	}
+	return FormatJSON
+}
+
+// LineError is a problem at one line of an imported file
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+func lineError(line int, format string, a ...any) error {
+	return &LineError{Line: line, Err: fmt.Errorf("%w: "+format, append([]any{ErrMalformed}, a...)...)}
+}
+
+// Export writes the registry in format f
+func (r *Registry) Export(w io.Writer, f Format) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatYAML:
+		return r.writeYAML(w)
+	case FormatGob:
+		return r.WriteGob(w)
+	}
+	return fmt.Errorf("fleet: unknown format %q", f)
+}
+
+// Import reads a registry written in format f
+// Malformed input is reported as a *LineError wrapping ErrMalformed, naming the line it was found on
+func Import(rd io.Reader, f Format) (*Registry, error) {
+	r := New()
+	var err error
+	switch f {
+	case FormatJSON:
+		err = r.readJSON(rd)
+	case FormatCSV:
+		err = r.readCSV(rd)
+	case FormatYAML:
+		err = r.readYAML(rd)
+	case FormatGob:
+		err = r.ReadGob(rd)
+	default:
+		err = fmt.Errorf("fleet: unknown format %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Merge adds every owner and engine of other to r, owners already in r keep their engines and get the new ones appended
+func (r *Registry) Merge(other *Registry) error {
+	for _, entry := range other.List() {
+		if err := r.AddOwner(entry.Owner); err != nil {
+			return err
+		}
+		for _, e := range entry.Engines {
+			if _, err := r.Add(entry.Owner, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readJSON turns the byte offsets of encoding/json errors into line numbers
+func (r *Registry) readJSON(rd io.Reader) error {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	// Only errors straight from json.Unmarshal have offsets into data, ones from decoding a single engine do not
+	switch err := json.Unmarshal(data, r).(type) {
+	case *json.SyntaxError:
+		return &LineError{Line: lineAt(data, err.Offset), Err: fmt.Errorf("%w: %v", ErrMalformed, err)}
+	case *json.UnmarshalTypeError:
+		return &LineError{Line: lineAt(data, err.Offset), Err: fmt.Errorf("%w: %v", ErrMalformed, err)}
+	default:
+		return err
+	}
+}
+
+func lineAt(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// engineFields returns an engine's fields without its "type", nested objects flattened to dotted names
+func engineFields(e vehicle.Engine) (string, map[string]any, error) {
+	params, err := vehicle.ParamsOf(e)
+	if err != nil {
+		return "", nil, err
+	}
+	kind := params["type"].(string)
+	delete(params, "type")
+	fields := make(map[string]any)
+	if err := flatten("", params, fields); err != nil {
+		return "", nil, fmt.Errorf("%s engine: %w", kind, err)
+	}
+	return kind, fields, nil
+}
+
+func flatten(prefix string, params map[string]any, out map[string]any) error {
+	for key, value := range params {
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(prefix+key+".", v, out); err != nil {
+				return err
+			}
+		case []any:
+			return fmt.Errorf("field %s: lists cannot be written as one value", prefix+key)
+		case nil:
+		default:
+			out[prefix+key] = v
+		}
+	}
+	return nil
+}
+
+// unflatten is the reverse of flatten
+func unflatten(fields map[string]any) vehicle.Params {
+	params := make(vehicle.Params)
+	for name, value := range fields {
+		m := map[string]any(params)
+		parts := strings.Split(name, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := m[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				m[part] = child
+			}
+			m = child
+		}
+		m[parts[len(parts)-1]] = value
+	}
+	return params
+}
+
+// fieldTypes returns the flattened fields of a zero engine of kind, their values tell which cells are numbers or text
+// A factory that refuses empty params gives no template, and cells are then read by guessing
+func fieldTypes(kind string) map[string]any {
+	e, err := vehicle.New(kind, vehicle.Params{})
+	if err != nil {
+		return nil
+	}
+	_, fields, err := engineFields(e)
+	if err != nil {
+		return nil
+	}
+	return fields
+}
+
+// cellValue reads the text of one CSV cell as the type the field has in template
+func cellValue(name, text string, template map[string]any) (any, error) {
+	switch template[name].(type) {
+	case string:
+		return text, nil
+	case json.Number, float64:
+		if !isNumber(text) {
+			return nil, fmt.Errorf("%s: %q is not a number", name, text)
+		}
+		return json.Number(text), nil
+	case bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not true or false", name, text)
+		}
+		return b, nil
+	}
+	return scalar(text), nil
+}
+
+// scalar guesses the type of an unquoted value: a number, true or false, or else text
+func scalar(text string) any {
+	if isNumber(text) {
+		return json.Number(text)
+	}
+	if b, err := strconv.ParseBool(text); err == nil && (text == "true" || text == "false") {
+		return b
+	}
+	return text
+}
+
+// isNumber reports whether text is a number as JSON writes them, so NaN or 0x1p3 stay text
+func isNumber(text string) bool {
+	return text != "" && (text[0] == '-' || text[0] >= '0' && text[0] <= '9') && json.Valid([]byte(text))
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+var csvHeader = []string{"owner_name", "owner_id", "owner_uuid", "type"}
+
+// writeCSV writes one row per engine, an owner without engines gets one row with an empty type
+func (r *Registry) writeCSV(w io.Writer) error {
+	type row struct {
+		owner  vehicle.EngineOwner
+		kind   string
+		fields map[string]any
+	}
+	var rows []row
+	columns := make(map[string]bool)
+	for _, entry := range r.List() {
+		if len(entry.Engines) == 0 {
+			rows = append(rows, row{owner: entry.Owner})
+		}
+		for _, e := range entry.Engines {
+			kind, fields, err := engineFields(e)
+			if err != nil {
+				return err
+			}
+			for name := range fields {
+				columns[name] = true
+			}
+			rows = append(rows, row{owner: entry.Owner, kind: kind, fields: fields})
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(columns))
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(slices.Clone(csvHeader), names...)); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var id string
+		if row.owner.ID != 0 {
+			id = strconv.FormatUint(row.owner.ID, 10)
+		}
+		record := []string{row.owner.Name, id, row.owner.UUID, row.kind}
+		for _, name := range names {
+			var cell string
+			if v, ok := row.fields[name]; ok {
+				cell = formatValue(v)
+			}
+			record = append(record, cell)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Registry) readCSV(rd io.Reader) error {
+	cr := csv.NewReader(rd)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil // an empty file is an empty fleet
+	}
+	if err != nil {
+		return csvError(err)
+	}
+	if len(header) < len(csvHeader) || !slices.Equal(header[:len(csvHeader)], csvHeader) {
+		return lineError(1, "header must start with %s", strings.Join(csvHeader, ","))
+	}
+	templates := make(map[string]map[string]any)
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return csvError(err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		owner := vehicle.EngineOwner{Name: record[0], OwnerID: vehicle.OwnerID{UUID: record[2]}}
+		if record[1] != "" {
+			if owner.ID, err = strconv.ParseUint(record[1], 10, 64); err != nil {
+				return lineError(line, "owner_id %q is not a number", record[1])
+			}
+		}
+		if err := r.AddOwner(owner); err != nil {
+			return &LineError{Line: line, Err: err}
+		}
+		kind := record[3]
+		if kind == "" {
+			continue // an owner without engines
+		}
+
+		if _, ok := templates[kind]; !ok {
+			templates[kind] = fieldTypes(kind)
+		}
+		fields := make(map[string]any)
+		for i, text := range record[len(csvHeader):] {
+			if text == "" {
+				continue
+			}
+			name := header[len(csvHeader)+i]
+			if fields[name], err = cellValue(name, text, templates[kind]); err != nil {
+				return lineError(line, "%v", err)
+			}
+		}
+		params := unflatten(fields)
+		params["type"] = kind
+		e, err := vehicle.FromParams(params)
+		if err != nil {
+			return &LineError{Line: line, Err: fmt.Errorf("%w: %v", ErrMalformed, err)}
+		}
+		if _, err := r.Add(owner, e); err != nil {
+			return &LineError{Line: line, Err: err}
+		}
+	}
+}
+
+// csvError keeps the line number encoding/csv already found, e.g. for a row with too many columns
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &LineError{Line: parseErr.Line, Err: fmt.Errorf("%w: %v", ErrMalformed, parseErr.Err)}
+	}
+	return err
+}
+
+/*
+
+	The YAML-like format
+
+	owners:
+	  - name: Donne
+	    id: 1
+	    engines:
+	      - type: gas
+	        mpg: 25
+	        owner:
+	          name: Donne
+	          id: 1
+
+	Indentation is two spaces per level, text that could be mistaken for a number or holds a ':' or '#' is quoted
+
+*/
+
+func (r *Registry) writeYAML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	entries := r.List()
+	if len(entries) == 0 {
+		fmt.Fprintln(bw, "owners: []")
+		return bw.Flush()
+	}
+	fmt.Fprintln(bw, "owners:")
+	for _, entry := range entries {
+		fmt.Fprintf(bw, "  - name: %s\n", quoteYAML(entry.Owner.Name))
+		if entry.Owner.ID != 0 {
+			fmt.Fprintf(bw, "    id: %d\n", entry.Owner.ID)
+		}
+		if entry.Owner.UUID != "" {
+			fmt.Fprintf(bw, "    uuid: %s\n", quoteYAML(entry.Owner.UUID))
+		}
+		if len(entry.Engines) == 0 {
+			continue
+		}
+		fmt.Fprintln(bw, "    engines:")
+		for _, e := range entry.Engines {
+			params, err := vehicle.ParamsOf(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "      - type: %s\n", quoteYAML(params["type"].(string)))
+			delete(params, "type")
+			if err := writeYAMLMap(bw, "        ", params); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func writeYAMLMap(w io.Writer, indent string, m map[string]any) error {
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		switch v := m[key].(type) {
+		case map[string]any:
+			fmt.Fprintf(w, "%s%s:\n", indent, key)
+			if err := writeYAMLMap(w, indent+"  ", v); err != nil {
+				return err
+			}
+		case []any:
+			return fmt.Errorf("field %s: lists are not supported", key)
+		case nil:
+		case string:
+			fmt.Fprintf(w, "%s%s: %s\n", indent, key, quoteYAML(v))
+		default:
+			fmt.Fprintf(w, "%s%s: %s\n", indent, key, formatValue(v))
+		}
+	}
+	return nil
+}
+
+// quoteYAML quotes text that would otherwise read back as something else
+func quoteYAML(s string) string {
+	if _, isText := scalar(s).(string); !isText || s == "" || s != strings.TrimSpace(s) ||
+		strings.ContainsAny(s, "\":#\n") || strings.HasPrefix(s, "-") || s == "[]" {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// yamlLine is one non-blank, non-comment line
+type yamlLine struct {
+	number int
+	indent int
+	item   bool // starts with "- "
+	key    string
+	value  string
+	scalar bool // has a value after the colon, otherwise a nested block follows
+}
+
+// yamlMap is a parsed block, it remembers its first line and the line of each key for error messages
+type yamlMap struct {
+	line   int
+	values map[string]any // string, bool, json.Number, *yamlMap or []*yamlMap
+	lines  map[string]int
+}
+
+func newYAMLMap(line int) *yamlMap {
+	return &yamlMap{line: line, values: make(map[string]any), lines: make(map[string]int)}
+}
+
+// lineOf finds the line of a dotted key path such as "owner.id", or of as much of the path as exists
+func (m *yamlMap) lineOf(path string) int {
+	key, rest, nested := strings.Cut(path, ".")
+	line, ok := m.lines[key]
+	if !ok {
+		return m.line
+	}
+	if sub, isMap := m.values[key].(*yamlMap); nested && isMap {
+		return sub.lineOf(rest)
+	}
+	return line
+}
+
+// engineError places an error from vehicle.FromParams on the line of the field it names, if it names one
+func (m *yamlMap) engineError(err error) error {
+	line := m.line
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		line = m.lineOf(typeErr.Field)
+	} else if _, field, ok := strings.Cut(err.Error(), "unknown field "); ok {
+		if name, err := strconv.Unquote(field); err == nil {
+			line = m.lineOf(name)
+		}
+	}
+	return &LineError{Line: line, Err: fmt.Errorf("%w: %v", ErrMalformed, err)}
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (r *Registry) readYAML(rd io.Reader) error {
+	var p yamlParser
+	scanner := bufio.NewScanner(rd)
+	for number := 1; scanner.Scan(); number++ {
+		text := scanner.Text()
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return lineError(number, "indent with spaces, not tabs")
+		}
+		l := yamlLine{number: number, indent: len(text) - len(trimmed)}
+		if rest, ok := strings.CutPrefix(trimmed, "- "); ok {
+			l.item = true
+			trimmed = rest
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || key == "" {
+			return lineError(number, "expected key: value, got %q", strings.TrimSpace(text))
+		}
+		l.key = strings.TrimSpace(key)
+		l.value = strings.TrimSpace(value)
+		l.scalar = l.value != ""
+		p.lines = append(p.lines, l)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(p.lines) == 0 {
+		return nil
+	}
+
+	doc, err := p.parseMap(0)
+	if err != nil {
+		return err
+	}
+	if p.pos < len(p.lines) {
+		return lineError(p.lines[p.pos].number, "unexpected indentation")
+	}
+	for key := range doc.values {
+		if key != "owners" {
+			return lineError(doc.lineOf(key), "unknown key %q", key)
+		}
+	}
+	owners, ok := yamlList(doc.values["owners"])
+	if !ok {
+		return lineError(doc.line, "owners must be a list")
+	}
+
+	for _, o := range owners {
+		owner, engines, err := yamlOwner(o)
+		if err != nil {
+			return err
+		}
+		if err := r.AddOwner(owner); err != nil {
+			return &LineError{Line: o.line, Err: err}
+		}
+		for _, em := range engines {
+			params, err := em.params()
+			if err != nil {
+				return err
+			}
+			e, err := vehicle.FromParams(params)
+			if err != nil {
+				return em.engineError(err)
+			}
+			if _, err := r.Add(owner, e); err != nil {
+				return &LineError{Line: em.line, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func yamlOwner(m *yamlMap) (vehicle.EngineOwner, []*yamlMap, error) {
+	var owner vehicle.EngineOwner
+	var engines []*yamlMap
+	for key, value := range m.values {
+		switch key {
+		case "name":
+			owner.Name = formatValue(value)
+		case "uuid":
+			owner.UUID = formatValue(value)
+		case "id":
+			id, err := strconv.ParseUint(formatValue(value), 10, 64)
+			if err != nil {
+				return owner, nil, lineError(m.lineOf(key), "owner id %v is not a number", value)
+			}
+			owner.ID = id
+		case "engines":
+			list, ok := yamlList(value)
+			if !ok {
+				return owner, nil, lineError(m.lineOf(key), "engines must be a list")
+			}
+			engines = list
+		default:
+			return owner, nil, lineError(m.lineOf(key), "unknown owner key %q", key)
+		}
+	}
+	return owner, engines, nil
+}
+
+// yamlList accepts a list, [] or a key with nothing below it, the last two are empty lists
+func yamlList(value any) ([]*yamlMap, bool) {
+	switch v := value.(type) {
+	case []*yamlMap:
+		return v, true
+	case *yamlMap:
+		return nil, len(v.values) == 0
+	case nil:
+		return nil, true
+	}
+	return nil, value == "[]"
+}
+
+// params converts a parsed engine block to the params vehicle.FromParams expects
+func (m *yamlMap) params() (vehicle.Params, error) {
+	params := make(vehicle.Params, len(m.values))
+	for key, value := range m.values {
+		switch v := value.(type) {
+		case *yamlMap:
+			nested, err := v.params()
+			if err != nil {
+				return nil, err
+			}
+			params[key] = map[string]any(nested)
+		case []*yamlMap:
+			return nil, lineError(m.lineOf(key), "field %s: lists are not supported", key)
+		default:
+			params[key] = v
+		}
+	}
+	return params, nil
+}
+
+// parseMap reads "key: value" lines at indent, a key without a value owns the deeper lines below it
+func (p *yamlParser) parseMap(indent int) (*yamlMap, error) {
+	m := newYAMLMap(p.lines[p.pos].number)
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || l.item {
+			break
+		}
+		if l.indent > indent {
+			return nil, lineError(l.number, "unexpected indentation")
+		}
+		if _, dup := m.values[l.key]; dup {
+			return nil, lineError(l.number, "duplicate key %q", l.key)
+		}
+		p.pos++
+		m.lines[l.key] = l.number
+		if l.scalar {
+			value, err := yamlScalar(l)
+			if err != nil {
+				return nil, err
+			}
+			m.values[l.key] = value
+			continue
+		}
+		// The block is the deeper lines below, a list may also start at the key's own indent
+		if p.pos == len(p.lines) {
+			m.values[l.key] = newYAMLMap(l.number)
+			break
+		}
+		next := p.lines[p.pos]
+		if next.indent < indent || next.indent == indent && !next.item {
+			m.values[l.key] = newYAMLMap(l.number) // empty block
+			continue
+		}
+		var err error
+		if next.item {
+			m.values[l.key], err = p.parseList(next.indent)
+		} else {
+			m.values[l.key], err = p.parseMap(next.indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// parseList reads "- " items at indent, each item is a block whose first key shares the dash's line
+func (p *yamlParser) parseList(indent int) ([]*yamlMap, error) {
+	var list []*yamlMap
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if !l.item || l.indent != indent {
+			if l.indent > indent {
+				return nil, lineError(l.number, "unexpected indentation")
+			}
+			break
+		}
+		// The item's first key sits two columns right of the dash, like the keys on the lines below it
+		p.lines[p.pos].item = false
+		p.lines[p.pos].indent = indent + 2
+		m, err := p.parseMap(indent + 2)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+func yamlScalar(l yamlLine) (any, error) {
+	if strings.HasPrefix(l.value, `"`) {
+		text, err := strconv.Unquote(l.value)
+		if err != nil {
+			return nil, lineError(l.number, "bad quoted text %s", l.value)
+		}
+		return text, nil
+	}
+	if l.value == "[]" {
+		return "[]", nil // an empty list, callers accept it wherever a list is expected
+	}
+	return scalar(l.value), nil
+}
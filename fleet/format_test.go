@@ -0,0 +1,133 @@
+package fleet
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// mixedRegistry has every built in engine type, an owner without engines and text that needs quoting
+func mixedRegistry(t *testing.T) *Registry {
+	t.Helper()
+	ann := vehicle.EngineOwner{Name: "Ann", OwnerID: vehicle.OwnerID{ID: 1}}
+	bob := vehicle.EngineOwner{Name: `Bob "B", Jr: #2`, OwnerID: vehicle.OwnerID{ID: 18446744073709551615}}
+	cat := vehicle.EngineOwner{Name: "Cat", OwnerID: vehicle.OwnerID{UUID: "0b5f2c3e-8a41-4d6e-9f0a-1c2d3e4f5a6b"}}
+	dan := vehicle.EngineOwner{Name: "true", OwnerID: vehicle.OwnerID{ID: 7, UUID: "5e0c1b2a-3d4f-4a5b-8c6d-7e8f9a0b1c2d"}}
+
+	r := New()
+	engines := []struct {
+		owner  vehicle.EngineOwner
+		engine vehicle.Engine
+	}{
+		{ann, vehicle.GasEngine{MPG: 25.5, Gallons: 0.1, TankGallons: 14, OwnerInfo: ann}},
+		{ann, vehicle.ElectricEngine{MPKWh: 3, KWh: 42.25, BatteryKWh: 75, OwnerInfo: ann}},
+		{bob, vehicle.HybridEngine{
+			GasEngine:      vehicle.GasEngine{MPG: 40, Gallons: 10, TankGallons: 11},
+			ElectricEngine: vehicle.ElectricEngine{MPKWh: 3, KWh: 12, BatteryKWh: 12},
+			Order:          vehicle.Blended,
+			OwnerInfo:      bob,
+		}},
+		{cat, vehicle.HydrogenEngine{MilesPerKg: 60, Kg: 5, TankKg: 5.6, OwnerInfo: cat}},
+		{cat, vehicle.GasEngine{MPG: 30, Gallons: 2}},
+	}
+	for _, e := range engines {
+		if _, err := r.Add(e.owner, e.engine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.AddOwner(dan); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatCSV, FormatYAML, FormatGob} {
+		t.Run(string(f), func(t *testing.T) {
+			want := mixedRegistry(t)
+			var buf bytes.Buffer
+			if err := want.Export(&buf, f); err != nil {
+				t.Fatal(err)
+			}
+			exported := buf.String()
+
+			got, err := Import(&buf, f)
+			if err != nil {
+				t.Fatalf("Import: %v\n%s", err, exported)
+			}
+			if !reflect.DeepEqual(got.List(), want.List()) {
+				t.Errorf("round trip changed the fleet\ngot:  %+v\nwant: %+v", got.List(), want.List())
+			}
+
+			// Exporting what was imported gives the same file, nothing drifts on a second trip
+			var again bytes.Buffer
+			if err := got.Export(&again, f); err != nil {
+				t.Fatal(err)
+			}
+			if f != FormatGob && again.String() != exported {
+				t.Errorf("second export differs\nfirst:\n%s\nsecond:\n%s", exported, again.String())
+			}
+		})
+	}
+}
+
+func TestImportEmpty(t *testing.T) {
+	for _, f := range []Format{FormatCSV, FormatYAML} {
+		r, err := Import(strings.NewReader(""), f)
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		if n := len(r.List()); n != 0 {
+			t.Errorf("%s: empty input gave %d owners", f, n)
+		}
+	}
+}
+
+func TestImportLineErrors(t *testing.T) {
+	const csvHead = "owner_name,owner_id,owner_uuid,type,gallons,mpg\n"
+	const yamlHead = "owners:\n  - name: A\n    id: 1\n    engines:\n      - type: gas\n"
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		line   int
+		text   string // part of the error message
+	}{
+		{"csv header", FormatCSV, "name,id\n", 1, "header must start with"},
+		{"csv owner id", FormatCSV, csvHead + "A,1,,gas,3,25\nB,x,,gas,3,25\n", 3, `owner_id "x" is not a number`},
+		{"csv number", FormatCSV, csvHead + "A,1,,gas,3,25\nA,1,,gas,3,fast\n", 3, `mpg: "fast" is not a number`},
+		{"csv unknown type", FormatCSV, csvHead + "A,1,,rocket,3,25\n", 2, "rocket"},
+		{"csv columns", FormatCSV, csvHead + "A,1,,gas,3\n", 2, "wrong number of fields"},
+		{"yaml tab", FormatYAML, "owners:\n\t- name: A\n", 2, "tabs"},
+		{"yaml no colon", FormatYAML, "owners:\n  - name A\n", 2, "expected key: value"},
+		{"yaml owner id", FormatYAML, "owners:\n  - name: A\n    id: x1\n", 3, "owner id x1 is not a number"},
+		{"yaml number", FormatYAML, yamlHead + "        mpg: fast\n        gallons: 3\n", 6, "mpg"},
+		{"yaml unknown field", FormatYAML, yamlHead + "        mpg: 30\n        galons: 3\n", 7, `unknown field "galons"`},
+		{"yaml nested field", FormatYAML, yamlHead + "        mpg: 30\n        owner:\n          name: A\n          id: q\n", 9, "owner.id"},
+		{"yaml duplicate", FormatYAML, yamlHead + "        mpg: 30\n        mpg: 31\n", 7, `duplicate key "mpg"`},
+		{"yaml indentation", FormatYAML, yamlHead + "        mpg: 30\n           gallons: 3\n", 7, "unexpected indentation"},
+		{"yaml unknown owner key", FormatYAML, "owners:\n  - name: A\n    id: 1\n    colour: red\n", 4, `unknown owner key "colour"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(strings.NewReader(tt.input), tt.format)
+			var lineErr *LineError
+			if !errors.As(err, &lineErr) {
+				t.Fatalf("got %v, want a *LineError", err)
+			}
+			if lineErr.Line != tt.line {
+				t.Errorf("line = %d, want %d (%v)", lineErr.Line, tt.line, err)
+			}
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("%v does not wrap ErrMalformed", err)
+			}
+			if !strings.Contains(err.Error(), tt.text) {
+				t.Errorf("%q does not mention %q", err, tt.text)
+			}
+		})
+	}
+}
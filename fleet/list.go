@@ -0,0 +1,141 @@
+package fleet
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// SortKey names an order for Select
+type SortKey string
+
+const (
+	SortNone       SortKey = ""           // owner ID order, as List
+	SortRange      SortKey = "range"      // miles left
+	SortEfficiency SortKey = "efficiency" // miles per gallon equivalent, see vehicle.MPGe
+	SortOwnerName  SortKey = "owner"
+	SortOwnerID    SortKey = "id"
+)
+
+func ParseSortKey(s string) (SortKey, error) {
+	switch k := SortKey(s); k {
+	case SortNone, SortRange, SortEfficiency, SortOwnerName, SortOwnerID:
+		return k, nil
+	}
+	return "", fmt.Errorf("fleet: unknown sort key %q (want range, efficiency, owner or id)", s)
+}
+
+// Query picks and orders engines for Select, the zero Query selects every engine in List order
+type Query struct {
+	Sort       SortKey
+	Descending bool
+	Kind       string  // only engines registered under this type name, "" for every type
+	MinRange   float64 // only engines with at least this many miles left, engines without a range never pass
+}
+
+// Item is one engine returned by Select
+type Item struct {
+	Owner  vehicle.EngineOwner
+	Index  int // position within the owner's engines, as used by Update and Remove
+	Kind   string
+	Engine vehicle.Engine
+	Range  float64 // miles left, 0 when Err is set
+	MPGe   float64 // NaN when the engine cannot say how much energy it uses
+	Err    error   // why MilesLeft failed
+}
+
+// ByRange sorts items by miles left, it implements sort.Interface
+// sort.Interface is the older way to sort: a type says how long it is, how two elements compare and how to swap them
+// slices.SortFunc only needs the compare function, the other keys below use it
+type ByRange []Item
+
+func (b ByRange) Len() int           { return len(b) }
+func (b ByRange) Less(i, j int) bool { return b[i].Range < b[j].Range }
+func (b ByRange) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Select returns the engines matching q in the order it asks for
+// When sorting by range or efficiency, engines without that number come last whichever the direction
+func (r *Registry) Select(q Query) ([]Item, error) {
+	var items []Item
+	for _, entry := range r.List() {
+		for i, e := range entry.Engines {
+			kind, err := vehicle.KindOf(e)
+			if err != nil {
+				return nil, err
+			}
+			if q.Kind != "" && kind != q.Kind {
+				continue
+			}
+			item := Item{Owner: entry.Owner, Index: i, Kind: kind, Engine: e, MPGe: math.NaN()}
+			item.Range, item.Err = e.MilesLeft()
+			if item.Err != nil {
+				item.Range = 0
+			}
+			if q.MinRange > 0 && (item.Err != nil || item.Range < q.MinRange) {
+				continue
+			}
+			if mpge, err := vehicle.MPGe(e); err == nil {
+				item.MPGe = mpge
+			}
+			items = append(items, item)
+		}
+	}
+
+	switch q.Sort {
+	case SortNone:
+		if q.Descending {
+			slices.Reverse(items)
+		}
+	case SortRange:
+		known, unknown := partition(items, func(it Item) bool { return it.Err == nil })
+		var order sort.Interface = ByRange(known)
+		if q.Descending {
+			order = sort.Reverse(order) // flips Less, so the largest range comes first
+		}
+		sort.Stable(order) // Stable keeps engines with the same range in owner order
+		items = append(known, unknown...)
+	case SortEfficiency:
+		known, unknown := partition(items, func(it Item) bool { return !math.IsNaN(it.MPGe) })
+		slices.SortFunc(known, direction(q.Descending, func(a, b Item) int {
+			return cmp.Or(cmp.Compare(a.MPGe, b.MPGe), compareItems(a, b))
+		}))
+		items = append(known, unknown...)
+	case SortOwnerName:
+		slices.SortFunc(items, direction(q.Descending, func(a, b Item) int {
+			return cmp.Or(cmp.Compare(a.Owner.Name, b.Owner.Name), compareItems(a, b))
+		}))
+	case SortOwnerID:
+		slices.SortFunc(items, direction(q.Descending, compareItems))
+	default:
+		return nil, fmt.Errorf("fleet: unknown sort key %q", q.Sort)
+	}
+	return items, nil
+}
+
+// compareItems orders by owner ID then engine index, it breaks ties so SortFunc's order never depends on the input order
+func compareItems(a, b Item) int {
+	return cmp.Or(compareIDs(a.Owner.OwnerID, b.Owner.OwnerID), cmp.Compare(a.Index, b.Index))
+}
+
+func direction(descending bool, compare func(a, b Item) int) func(a, b Item) int {
+	if !descending {
+		return compare
+	}
+	return func(a, b Item) int { return compare(b, a) }
+}
+
+// partition splits items into those keep accepts and the rest, both in their original order
+func partition(items []Item, keep func(Item) bool) (yes, no []Item) {
+	for _, it := range items {
+		if keep(it) {
+			yes = append(yes, it)
+		} else {
+			no = append(no, it)
+		}
+	}
+	return yes, no
+}
@@ -0,0 +1,147 @@
+package fleet
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// EncodeEngine writes e as a JSON object with a "type" field next to the engine's own fields
+// The type name is whatever the engine was registered under with vehicle.Register
+func EncodeEngine(e vehicle.Engine) ([]byte, error) {
+	params, err := vehicle.ParamsOf(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(params)
+}
+
+// DecodeEngine reads an object written by EncodeEngine, or any config object with a registered "type"
+func DecodeEngine(data []byte) (vehicle.Engine, error) {
+	return vehicle.FromJSON(data)
+}
+
+// jsonEntry is how an Entry is written to a JSON file
+type jsonEntry struct {
+	vehicle.EngineOwner
+	Engines []json.RawMessage `json:"engines"`
+}
+
+func (r *Registry) MarshalJSON() ([]byte, error) {
+	entries := r.List()
+	out := struct {
+		Owners []jsonEntry `json:"owners"`
+	}{Owners: make([]jsonEntry, 0, len(entries))}
+	for _, entry := range entries {
+		je := jsonEntry{EngineOwner: entry.Owner}
+		for _, e := range entry.Engines {
+			data, err := EncodeEngine(e)
+			if err != nil {
+				return nil, err
+			}
+			je.Engines = append(je.Engines, data)
+		}
+		out.Owners = append(out.Owners, je)
+	}
+	return json.Marshal(out)
+}
+
+func (r *Registry) UnmarshalJSON(data []byte) error {
+	var in struct {
+		Owners []jsonEntry `json:"owners"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	loaded := New()
+	for _, je := range in.Owners {
+		if err := loaded.AddOwner(je.EngineOwner); err != nil {
+			return err
+		}
+		for i, raw := range je.Engines {
+			e, err := DecodeEngine(raw)
+			if err != nil {
+				return fmt.Errorf("owner %v engine %d: %w", je.OwnerID, i, err)
+			}
+			if _, err := loaded.Add(je.EngineOwner, e); err != nil {
+				return err
+			}
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owners = loaded.owners
+	return nil
+}
+
+// WriteGob writes the registry with encoding/gob
+func (r *Registry) WriteGob(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(r.List())
+}
+
+// ReadGob replaces the registry with one written by WriteGob
+func (r *Registry) ReadGob(rd io.Reader) error {
+	var entries []Entry
+	if err := gob.NewDecoder(rd).Decode(&entries); err != nil {
+		return err
+	}
+	loaded := New()
+	for _, entry := range entries {
+		if err := loaded.AddOwner(entry.Owner); err != nil {
+			return err
+		}
+		for _, e := range entry.Engines {
+			if _, err := loaded.Add(entry.Owner, e); err != nil {
+				return err
+			}
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owners = loaded.owners
+	return nil
+}
+
+// Load reads a registry from path in the format its extension names, see FormatOf
+// A file that does not exist yet gives an empty registry, so the first Save creates it
+func Load(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := Import(f, FormatOf(path))
+	if err != nil {
+		return nil, fmt.Errorf("fleet: reading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Save writes the registry to path in the format Load expects
+// It writes a temporary file first and renames it, so a failed save never leaves half a fleet behind
+func (r *Registry) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename succeeded
+
+	if err := r.Export(tmp, FormatOf(path)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("fleet: writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
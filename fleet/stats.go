@@ -0,0 +1,122 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/donnebaldemeca/GoBasics/stats"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+// Group summarises the ranges of some of the fleet's engines
+type Group struct {
+	Name    string                 `json:"name"`
+	Engines int                    `json:"engines"`
+	Range   stats.Summary[float64] `json:"range_miles"` // zero when no engine in the group has a range
+}
+
+// Report is the fleet's ranges summarised as a whole, per engine type and per owner
+type Report struct {
+	Fleet           Group              `json:"fleet"`
+	ByType          []Group            `json:"by_type"`  // sorted by type name
+	ByOwner         []Group            `json:"by_owner"` // in List order
+	EnginesPerOwner stats.Summary[int] `json:"engines_per_owner"`
+	Failed          int                `json:"failed"` // engines whose MilesLeft failed, they count as engines but have no range
+}
+
+// Stats builds the report, the same generic helpers summarise float64 ranges and int engine counts
+func (r *Registry) Stats() (Report, error) {
+	var report Report
+	var all []float64
+	var perOwner []int
+	byType := make(map[string][]float64)
+	typeCounts := make(map[string]int)
+
+	for _, entry := range r.List() {
+		var ranges []float64
+		for _, e := range entry.Engines {
+			kind, err := vehicle.KindOf(e)
+			if err != nil {
+				kind = fmt.Sprintf("%T", e)
+			}
+			typeCounts[kind]++
+			left, err := e.MilesLeft()
+			if err != nil {
+				report.Failed++
+				continue
+			}
+			ranges = append(ranges, left)
+			byType[kind] = append(byType[kind], left)
+		}
+		all = append(all, ranges...)
+		perOwner = append(perOwner, len(entry.Engines))
+		group, err := newGroup(entry.Owner.String(), len(entry.Engines), ranges)
+		if err != nil {
+			return Report{}, err
+		}
+		report.ByOwner = append(report.ByOwner, group)
+	}
+
+	var err error
+	if report.Fleet, err = newGroup("fleet", stats.Sum(perOwner), all); err != nil {
+		return Report{}, err
+	}
+	for _, kind := range slices.Sorted(maps.Keys(typeCounts)) {
+		group, err := newGroup(kind, typeCounts[kind], byType[kind])
+		if err != nil {
+			return Report{}, err
+		}
+		report.ByType = append(report.ByType, group)
+	}
+	if report.EnginesPerOwner, err = stats.Summarize(perOwner); err != nil && !errors.Is(err, stats.ErrEmpty) {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// newGroup leaves Range zero for a group without ranges, an empty fleet is not an error
+func newGroup(name string, engines int, ranges []float64) (Group, error) {
+	group := Group{Name: name, Engines: engines}
+	var err error
+	if group.Range, err = stats.Summarize(ranges); err != nil && !errors.Is(err, stats.ErrEmpty) {
+		return Group{}, err
+	}
+	return group, nil
+}
+
+// WriteReport prints the report as one aligned table, distances in units
+func WriteReport(w io.Writer, report Report, u vehicle.UnitSystem) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	block := func(title string, groups ...Group) {
+		fmt.Fprintf(tw, "%s\tENGINES\tTOTAL\tMEAN\tMEDIAN\tMIN\tMAX\tP10\tP90\n", title)
+		for _, g := range groups {
+			if g.Range.Count == 0 {
+				fmt.Fprintf(tw, "%s\t%d\t-\n", g.Name, g.Engines)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%d", g.Name, g.Engines)
+			for _, miles := range []float64{g.Range.Total, g.Range.Mean, g.Range.Median, g.Range.Min, g.Range.Max, g.Range.P10, g.Range.P90} {
+				fmt.Fprintf(tw, "\t%s", vehicle.FormatDistance(miles, u))
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+	block("FLEET", report.Fleet)
+	block("TYPE", report.ByType...)
+	block("OWNER", report.ByOwner...)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if report.EnginesPerOwner.Count > 0 {
+		fmt.Fprintf(w, "Engines per owner: %v\n", report.EnginesPerOwner)
+	}
+	if report.Failed > 0 {
+		fmt.Fprintf(w, "%d engine(s) have no range and are left out of the ranges\n", report.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package fleetrpc serves a fleet's engine operations with net/rpc and the JSON-RPC codec, and calls them
+package fleetrpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+/*
+
+	JSON-RPC
+
+	net/rpc calls exported methods of the form
+		func (t *T) Name(args A, reply *R) error
+	by the name "Service.Name", jsonrpc encodes each call as one JSON object on the connection:
+
+	{"method": "Fleet.CanDrive", "params": [{"owner": "1", "index": 0, "miles": 120}], "id": 1}
+
+	Methods: Fleet.MilesLeft, Fleet.CanDrive, Fleet.Refuel and Fleet.List
+
+*/
+
+// ServiceName is the name the service is registered under
+const ServiceName = "Fleet"
+
+// EngineRef names one engine, the owner ID is a number or a UUID written as text
+type EngineRef struct {
+	Owner string `json:"owner"`
+	Index int    `json:"index"`
+}
+
+type CanDriveArgs struct {
+	EngineRef
+	Miles float64 `json:"miles"`
+}
+
+// RefuelArgs adds Amount of Source to an engine, an Amount of 0 fills every tank and battery
+type RefuelArgs struct {
+	EngineRef
+	Source vehicle.Source `json:"source"`
+	Amount float64        `json:"amount"`
+}
+
+type ListArgs struct {
+	Owner string `json:"owner"`
+}
+
+// EngineInfo describes one engine in a reply
+type EngineInfo struct {
+	Index     int            `json:"index"`
+	Engine    vehicle.Params `json:"engine"`
+	MilesLeft float64        `json:"miles_left"`
+	Error     string         `json:"error,omitempty"` // why MilesLeft failed
+}
+
+// Service holds the methods net/rpc exposes, every exported method must have the RPC signature
+type Service struct {
+	registry *fleet.Registry
+	save     func() error // called after Refuel, nil keeps changes in memory only
+	mu       sync.Mutex
+}
+
+// NewService returns the service for registry, save is called after every change and may be nil
+func NewService(registry *fleet.Registry, save func() error) *Service {
+	return &Service{registry: registry, save: save}
+}
+
+func (s *Service) MilesLeft(args EngineRef, reply *float64) error {
+	_, e, err := s.engine(args)
+	if err != nil {
+		return err
+	}
+	*reply, err = e.MilesLeft()
+	return err
+}
+
+func (s *Service) CanDrive(args CanDriveArgs, reply *vehicle.Verdict) error {
+	_, e, err := s.engine(args.EngineRef)
+	if err != nil {
+		return err
+	}
+	*reply, err = vehicle.CanDrive(e, args.Miles)
+	return err
+}
+
+// Refuel changes the stored engine and replies with it, the change is undone when it cannot be saved
+func (s *Service) Refuel(args RefuelArgs, reply *EngineInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, old, err := s.engine(args.EngineRef)
+	if err != nil {
+		return err
+	}
+	e, err := vehicle.Refuel(old, args.Source, args.Amount)
+	if err != nil {
+		return err
+	}
+	if err := s.registry.Update(id, args.Index, e); err != nil {
+		return err
+	}
+	if s.save != nil {
+		if err := s.save(); err != nil {
+			s.registry.Update(id, args.Index, old) // a refuel that could not be saved is not kept either
+			return err
+		}
+	}
+	*reply, err = info(args.Index, e)
+	return err
+}
+
+// List replies with every engine of one owner
+func (s *Service) List(args ListArgs, reply *[]EngineInfo) error {
+	id, err := vehicle.ParseOwnerID(args.Owner)
+	if err != nil {
+		return err
+	}
+	entry, ok := s.registry.Get(id)
+	if !ok {
+		return fmt.Errorf("%w: ID %v", fleet.ErrNoOwner, id)
+	}
+	engines := make([]EngineInfo, 0, len(entry.Engines))
+	for i, e := range entry.Engines {
+		engine, err := info(i, e)
+		if err != nil {
+			return err
+		}
+		engines = append(engines, engine)
+	}
+	*reply = engines
+	return nil
+}
+
+func (s *Service) engine(ref EngineRef) (vehicle.OwnerID, vehicle.Engine, error) {
+	id, err := vehicle.ParseOwnerID(ref.Owner)
+	if err != nil {
+		return id, nil, err
+	}
+	entry, ok := s.registry.Get(id)
+	if !ok {
+		return id, nil, fmt.Errorf("%w: ID %v", fleet.ErrNoOwner, id)
+	}
+	if ref.Index < 0 || ref.Index >= len(entry.Engines) {
+		return id, nil, fmt.Errorf("%w: owner %v has %d engines, no index %d", fleet.ErrNoEngine, id, len(entry.Engines), ref.Index)
+	}
+	return id, entry.Engines[ref.Index], nil
+}
+
+func info(index int, e vehicle.Engine) (EngineInfo, error) {
+	params, err := vehicle.ParamsOf(e)
+	if err != nil {
+		return EngineInfo{}, err
+	}
+	engine := EngineInfo{Index: index, Engine: params}
+	if engine.MilesLeft, err = e.MilesLeft(); err != nil {
+		engine.Error = err.Error()
+	}
+	return engine, nil
+}
+
+// Serve accepts connections on l and answers JSON-RPC calls on each one in its own go routine
+// It returns when l is closed, with nil if it was closed on purpose
+func Serve(l net.Listener, s *Service) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, s); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// Client calls a fleet service, network is "tcp" or "unix" as for net.Dial
+type Client struct {
+	rpc *rpc.Client
+}
+
+func Dial(network, address string) (*Client, error) {
+	c, err := jsonrpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpc: c}, nil
+}
+
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+func (c *Client) MilesLeft(ref EngineRef) (float64, error) {
+	var left float64
+	err := c.rpc.Call(ServiceName+".MilesLeft", ref, &left)
+	return left, err
+}
+
+func (c *Client) CanDrive(ref EngineRef, miles float64) (vehicle.Verdict, error) {
+	var verdict vehicle.Verdict
+	err := c.rpc.Call(ServiceName+".CanDrive", CanDriveArgs{EngineRef: ref, Miles: miles}, &verdict)
+	return verdict, err
+}
+
+func (c *Client) Refuel(ref EngineRef, source vehicle.Source, amount float64) (EngineInfo, error) {
+	var engine EngineInfo
+	err := c.rpc.Call(ServiceName+".Refuel", RefuelArgs{EngineRef: ref, Source: source, Amount: amount}, &engine)
+	return engine, err
+}
+
+func (c *Client) List(owner string) ([]EngineInfo, error) {
+	var engines []EngineInfo
+	err := c.rpc.Call(ServiceName+".List", ListArgs{Owner: owner}, &engines)
+	return engines, err
+}
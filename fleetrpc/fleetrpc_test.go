@@ -0,0 +1,162 @@
+package fleetrpc
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/donnebaldemeca/GoBasics/fleet"
+	"github.com/donnebaldemeca/GoBasics/vehicle"
+)
+
+var (
+	owner = vehicle.EngineOwner{Name: "Ada", OwnerID: vehicle.OwnerID{ID: 1}}
+	first = EngineRef{Owner: "1", Index: 0}
+)
+
+// serve starts a service on a Unix socket in a temporary directory and returns a client connected to it
+// The registry holds one owner with a gas engine that has 100 of its 375 miles left
+func serve(t *testing.T, save func(*fleet.Registry) error) (*Client, *fleet.Registry) {
+	t.Helper()
+	registry := fleet.New()
+	if _, err := registry.Add(owner, vehicle.GasEngine{MPG: 25, Gallons: 4, TankGallons: 15}); err != nil {
+		t.Fatal(err)
+	}
+	var saveFunc func() error
+	if save != nil {
+		saveFunc = func() error { return save(registry) }
+	}
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "fleet.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() { served <- Serve(l, NewService(registry, saveFunc)) }()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-served; err != nil {
+			t.Errorf("Serve = %v after closing the listener, want nil", err)
+		}
+	})
+
+	client, err := Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, registry
+}
+
+// milesLeft reads the range straight from the registry, not over RPC
+func milesLeft(t *testing.T, registry *fleet.Registry) float64 {
+	t.Helper()
+	entry, ok := registry.Get(owner.OwnerID)
+	if !ok {
+		t.Fatal("owner 1 is gone")
+	}
+	left, err := entry.Engines[0].MilesLeft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return left
+}
+
+func TestMilesLeftAndCanDrive(t *testing.T) {
+	client, _ := serve(t, nil)
+	if left, err := client.MilesLeft(first); err != nil || left != 100 {
+		t.Errorf("MilesLeft = %v, %v, want 100", left, err)
+	}
+	verdict, err := client.CanDrive(first, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict.Feasible || verdict.Shortfall != 20 {
+		t.Errorf("CanDrive(120) = %+v, want a shortfall of 20 miles", verdict)
+	}
+	if verdict, err := client.CanDrive(first, 80); err != nil || !verdict.Feasible {
+		t.Errorf("CanDrive(80) = %+v, %v, want it to be possible", verdict, err)
+	}
+}
+
+func TestRefuelSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.json")
+	client, _ := serve(t, func(r *fleet.Registry) error { return r.Save(path) })
+
+	engine, err := client.Refuel(first, vehicle.Gasoline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.Index != 0 || engine.MilesLeft != 375 || engine.Error != "" {
+		t.Errorf("Refuel replied %+v, want engine 0 with 375 miles left", engine)
+	}
+
+	saved, err := fleet.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left := milesLeft(t, saved); left != 375 {
+		t.Errorf("the saved engine has %v miles left, want 375", left)
+	}
+}
+
+func TestRefuelSaveFails(t *testing.T) {
+	errDiskFull := errors.New("disk full")
+	client, registry := serve(t, func(*fleet.Registry) error { return errDiskFull })
+
+	if engine, err := client.Refuel(first, vehicle.Gasoline, 5); err == nil || !strings.Contains(err.Error(), errDiskFull.Error()) {
+		t.Errorf("Refuel = %+v, %v, want the save error", engine, err)
+	}
+	if left := milesLeft(t, registry); left != 100 {
+		t.Errorf("the engine has %v miles left after a refuel that was not saved, want the 100 it had", left)
+	}
+}
+
+func TestList(t *testing.T) {
+	client, registry := serve(t, nil)
+	if _, err := registry.Add(owner, vehicle.ElectricEngine{MPKWh: 3, KWh: 10}); err != nil {
+		t.Fatal(err)
+	}
+	engines, err := client.List("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engines) != 2 || engines[0].MilesLeft != 100 || engines[1].Index != 1 || engines[1].MilesLeft != 30 {
+		t.Errorf("List = %+v, want the gas engine with 100 miles and the electric one with 30", engines)
+	}
+}
+
+// TestErrors checks the errors every method gives for a reference to nothing,
+// over RPC they arrive as text so the messages are compared
+func TestErrors(t *testing.T) {
+	client, _ := serve(t, func(*fleet.Registry) error { t.Error("a failed call saved"); return nil })
+	tests := []struct {
+		name string
+		ref  EngineRef
+		want string
+	}{
+		{"unknown owner", EngineRef{Owner: "2"}, fleet.ErrNoOwner.Error()},
+		{"bad index", EngineRef{Owner: "1", Index: 5}, fleet.ErrNoEngine.Error()},
+		{"negative index", EngineRef{Owner: "1", Index: -1}, fleet.ErrNoEngine.Error()},
+		{"not an owner ID", EngineRef{Owner: "Ada"}, "Ada"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := map[string]func() error{
+				"MilesLeft": func() error { _, err := client.MilesLeft(tt.ref); return err },
+				"CanDrive":  func() error { _, err := client.CanDrive(tt.ref, 10); return err },
+				"Refuel":    func() error { _, err := client.Refuel(tt.ref, vehicle.Gasoline, 1); return err },
+			}
+			if tt.ref.Index == 0 {
+				calls["List"] = func() error { _, err := client.List(tt.ref.Owner); return err }
+			}
+			for method, call := range calls {
+				if err := call(); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("%s = %v, want an error containing %q", method, err, tt.want)
+				}
+			}
+		})
+	}
+}
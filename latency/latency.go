@@ -0,0 +1,219 @@
+// Package latency draws the delays of simulated calls from a chosen distribution
+package latency
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+
+	Latency models
+
+	The total time of a concurrent fan-out is the time of its slowest call, so it depends on the tail of
+	the delay distribution far more than on its mean:
+		Fixed        every call takes the same time
+		Uniform      anything between Min and Max, equally likely
+		Normal       most calls near Mean, rarely far from it
+		Exponential  mostly short calls, now and then a long one
+		LogNormal    like the response times of real services: a typical Median and a long tail set by Sigma
+		Replay       the delays recorded in a trace file
+
+*/
+
+// Model draws one call's delay, every random number comes from rng so a seeded rng repeats the delays
+type Model interface {
+	Draw(rng *rand.Rand) time.Duration
+	String() string // the spec Parse reads back
+}
+
+type Fixed struct {
+	D time.Duration
+}
+
+func (f Fixed) Draw(*rand.Rand) time.Duration { return f.D }
+func (f Fixed) String() string                { return "fixed:" + f.D.String() }
+
+type Uniform struct {
+	Min, Max time.Duration
+}
+
+func (u Uniform) Draw(rng *rand.Rand) time.Duration {
+	return u.Min + time.Duration(rng.Float64()*float64(u.Max-u.Min))
+}
+func (u Uniform) String() string { return fmt.Sprintf("uniform:%v,%v", u.Min, u.Max) }
+
+// Normal is cut off at zero, a call cannot take negative time
+type Normal struct {
+	Mean, StdDev time.Duration
+}
+
+func (n Normal) Draw(rng *rand.Rand) time.Duration {
+	return clamp(float64(n.Mean) + rng.NormFloat64()*float64(n.StdDev))
+}
+func (n Normal) String() string { return fmt.Sprintf("normal:%v,%v", n.Mean, n.StdDev) }
+
+type Exponential struct {
+	Mean time.Duration
+}
+
+func (e Exponential) Draw(rng *rand.Rand) time.Duration {
+	return clamp(rng.ExpFloat64() * float64(e.Mean))
+}
+func (e Exponential) String() string { return "exponential:" + e.Mean.String() }
+
+// LogNormal has half its delays below Median, Sigma stretches the tail: with Sigma 1 one call in ten takes 3.6 times the median
+type LogNormal struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (l LogNormal) Draw(rng *rand.Rand) time.Duration {
+	return clamp(float64(l.Median) * math.Exp(l.Sigma*rng.NormFloat64()))
+}
+func (l LogNormal) String() string { return fmt.Sprintf("lognormal:%v,%g", l.Median, l.Sigma) }
+
+// maxDelay is the largest float64 below 1<<63, float64(math.MaxInt64) rounds up to 1<<63 which no longer fits a Duration
+const maxDelay = float64(math.MaxInt64 - 1023)
+
+// clamp turns a delay worked out in floating point into a Duration between 0 and the longest Duration
+// The tails of Normal, Exponential and LogNormal are unbounded, and converting a float too big for a Duration is implementation-defined
+func clamp(d float64) time.Duration {
+	if math.IsNaN(d) {
+		return 0
+	}
+	return time.Duration(min(max(d, 0), maxDelay))
+}
+
+// Replay draws from the delays of a recorded trace
+// Each call picks a recorded delay at random rather than the next one in the file,
+// so concurrent calls get the same delays on every run whatever order they ask in
+type Replay struct {
+	Path    string
+	Samples []time.Duration
+}
+
+func (r *Replay) Draw(rng *rand.Rand) time.Duration {
+	return r.Samples[rng.Intn(len(r.Samples))]
+}
+func (r *Replay) String() string { return "replay:" + r.Path }
+
+// Load reads a trace file: one delay per line, as a Go duration ("250ms") or a plain number of milliseconds
+// Blank lines and lines starting with # are skipped
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Replay{Path: path}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		d, err := parseDelay(text)
+		if err != nil {
+			return nil, fmt.Errorf("latency: %s line %d: %w", path, line, err)
+		}
+		r.Samples = append(r.Samples, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.Samples) == 0 {
+		return nil, fmt.Errorf("latency: %s holds no delays", path)
+	}
+	return r, nil
+}
+
+// parseDelay reads a Go duration or a plain number of milliseconds, NaN, infinite and negative delays are refused
+func parseDelay(s string) (time.Duration, error) {
+	var d time.Duration
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(ms) || math.IsInf(ms, 0) {
+			return 0, fmt.Errorf("delay %q is not a finite number of milliseconds", s)
+		}
+		if ms < 0 {
+			return 0, fmt.Errorf("negative delay %gms", ms)
+		}
+		if ms*float64(time.Millisecond) > maxDelay {
+			return 0, fmt.Errorf("delay %q is longer than the longest Duration", s)
+		}
+		d = time.Duration(ms * float64(time.Millisecond))
+	} else if d, err = time.ParseDuration(s); err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative delay %v", d)
+	}
+	return d, nil
+}
+
+// Parse reads a model spec, the name followed by its parameters:
+//
+//	fixed:2s  uniform:0s,2s  normal:1s,250ms  exponential:500ms  lognormal:400ms,1  replay:trace.txt
+//
+// exp is short for exponential, and a plain number is a number of milliseconds
+func Parse(spec string) (Model, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	if name == "replay" {
+		if args == "" {
+			return nil, errors.New("latency: replay needs a trace file, e.g. replay:trace.txt")
+		}
+		return Load(args)
+	}
+
+	var params []time.Duration
+	var sigma float64
+	for i, arg := range strings.Split(args, ",") {
+		if name == "lognormal" && i == 1 {
+			var err error
+			if sigma, err = strconv.ParseFloat(strings.TrimSpace(arg), 64); err != nil || math.IsNaN(sigma) || math.IsInf(sigma, 0) || sigma < 0 {
+				return nil, fmt.Errorf("latency: %q: sigma %q is not a finite number of at least 0", spec, arg)
+			}
+			continue
+		}
+		d, err := parseDelay(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("latency: %q: %w", spec, err)
+		}
+		params = append(params, d)
+	}
+
+	want := map[string]int{"fixed": 1, "uniform": 2, "normal": 2, "exponential": 1, "exp": 1, "lognormal": 1}
+	n, ok := want[name]
+	if !ok {
+		return nil, fmt.Errorf("latency: unknown model %q (want fixed, uniform, normal, exponential, lognormal or replay)", name)
+	}
+	if name == "lognormal" && !strings.Contains(args, ",") {
+		return nil, fmt.Errorf("latency: %q: lognormal needs a median and a sigma, e.g. lognormal:400ms,1", spec)
+	}
+	if len(params) != n {
+		return nil, fmt.Errorf("latency: %q: %s takes %d parameter(s)", spec, name, n)
+	}
+
+	switch name {
+	case "fixed":
+		return Fixed{D: params[0]}, nil
+	case "uniform":
+		if params[1] < params[0] {
+			return nil, fmt.Errorf("latency: %q: the maximum is below the minimum", spec)
+		}
+		return Uniform{Min: params[0], Max: params[1]}, nil
+	case "normal":
+		return Normal{Mean: params[0], StdDev: params[1]}, nil
+	case "exponential", "exp":
+		return Exponential{Mean: params[0]}, nil
+	}
+	return LogNormal{Median: params[0], Sigma: sigma}, nil
+}
@@ -0,0 +1,163 @@
+package latency
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// trace is the file the demo's --latency replay: flag is documented with
+const trace = "../cmd/main/testdata/db-latency.txt"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Model
+	}{
+		{"fixed:2s", Fixed{D: 2 * time.Second}},
+		{"fixed:250", Fixed{D: 250 * time.Millisecond}},
+		{"uniform:0s,2s", Uniform{Min: 0, Max: 2 * time.Second}},
+		{"normal:1s,250ms", Normal{Mean: time.Second, StdDev: 250 * time.Millisecond}},
+		{"exponential:500ms", Exponential{Mean: 500 * time.Millisecond}},
+		{"exp:500ms", Exponential{Mean: 500 * time.Millisecond}},
+		{"lognormal:400ms,1", LogNormal{Median: 400 * time.Millisecond, Sigma: 1}},
+		{"lognormal:400ms, 0.5", LogNormal{Median: 400 * time.Millisecond, Sigma: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			m, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m != tt.want {
+				t.Errorf("Parse = %#v, want %#v", m, tt.want)
+			}
+			// String gives a spec Parse reads back to the same model
+			if again, err := Parse(m.String()); err != nil || again != m {
+				t.Errorf("Parse(%q) = %#v, %v, want %#v", m.String(), again, err, m)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"gamma:1s",
+		"fixed",
+		"fixed:1s,2s",
+		"fixed:soon",
+		"fixed:-5ms",
+		"fixed:-5",
+		"fixed:NaN",
+		"fixed:Inf",
+		"fixed:-Inf",
+		"fixed:1e300",
+		"uniform:2s,1s",
+		"normal:1s",
+		"lognormal:400ms",
+		"lognormal:400ms,-1",
+		"lognormal:400ms,NaN",
+		"lognormal:400ms,Inf",
+		"lognormal:400ms,-Inf",
+		"lognormal:400ms,wide",
+		"replay:",
+		"replay:no-such-file.txt",
+	} {
+		if m, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", spec, m)
+		}
+	}
+}
+
+// TestDrawBounds draws from models whose tails reach below 0 or past the longest Duration,
+// every delay must still be between 0 and the longest Duration
+func TestDrawBounds(t *testing.T) {
+	longest := time.Duration(math.MaxInt64)
+	tests := []struct {
+		model      Model
+		reachesTop bool // some draws land in the top half of the Duration range, so the clamp is really tested
+	}{
+		{Normal{Mean: time.Second, StdDev: 5 * time.Second}, false},
+		{Normal{Mean: longest, StdDev: longest}, true},
+		{Exponential{Mean: longest / 2}, true},
+		{LogNormal{Median: time.Second, Sigma: 50}, true},
+		{LogNormal{Median: longest, Sigma: 1}, true},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		top := 0
+		for range 10000 {
+			d := tt.model.Draw(rng)
+			if d < 0 {
+				t.Fatalf("%v drew %v, a negative delay", tt.model, d)
+			}
+			if d > longest/2 {
+				top++
+			}
+		}
+		if tt.reachesTop && top == 0 {
+			t.Errorf("%v never drew a delay near the longest Duration", tt.model)
+		}
+	}
+}
+
+func TestDrawSeeded(t *testing.T) {
+	m := LogNormal{Median: 400 * time.Millisecond, Sigma: 1}
+	a, b := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	for range 100 {
+		if x, y := m.Draw(a), m.Draw(b); x != y {
+			t.Fatalf("two rngs with the same seed drew %v and %v", x, y)
+		}
+	}
+}
+
+func TestLoadTrace(t *testing.T) {
+	r, err := Load(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Samples) != 20 {
+		t.Fatalf("%d delays, want the 20 lines that are not comments", len(r.Samples))
+	}
+	if r.Samples[0] != 42*time.Millisecond || r.Samples[6] != 120*time.Millisecond || r.Samples[15] != 2400*time.Millisecond {
+		t.Errorf("samples %v, want 42ms first, 120ms for the plain number and 2.4s for the slowest", r.Samples)
+	}
+
+	m, err := Parse("replay:" + trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String() != "replay:"+trace {
+		t.Errorf("String() = %q, want the spec it was parsed from", m.String())
+	}
+	rng := rand.New(rand.NewSource(1))
+	for range 1000 {
+		if d := m.Draw(rng); !slices.Contains(r.Samples, d) {
+			t.Fatalf("replay drew %v, which is not in the trace", d)
+		}
+	}
+}
+
+func TestLoadBadTrace(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.txt":    "# nothing recorded\n\n",
+		"nan.txt":      "42ms\nNaN\n",
+		"inf.txt":      "42ms\n+Inf\n",
+		"negative.txt": "-3\n",
+		"garbage.txt":  "fast\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if r, err := Load(path); err == nil {
+			t.Errorf("Load(%s) = %v, want an error", name, r.Samples)
+		}
+	}
+}
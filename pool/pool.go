@@ -0,0 +1,86 @@
+// Package pool runs jobs on a fixed number of go routines instead of one go routine per job
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+/*
+
+	Worker pool
+
+	A go routine per job is fine for five jobs, for a hundred thousand it means a hundred thousand
+	stacks and as many calls hitting the database at once
+	A pool starts a fixed number of workers, the jobs wait in a queue (a channel) until a worker is free
+
+*/
+
+// Result is the outcome of one job, Index is the job's position in the slice handed to Run or Stream
+type Result[J, R any] struct {
+	Index int
+	Job   J
+	Value R
+	Err   error // the job's own error, one failing job does not stop the others
+}
+
+// Pool runs do over jobs with at most workers go routines at a time
+type Pool[J, R any] struct {
+	workers int
+	do      func(ctx context.Context, job J) (R, error)
+}
+
+// New returns a pool of workers go routines, fewer than one worker means one, so the jobs run sequentially
+func New[J, R any](workers int, do func(ctx context.Context, job J) (R, error)) *Pool[J, R] {
+	return &Pool[J, R]{workers: max(workers, 1), do: do}
+}
+
+func (p *Pool[J, R]) Workers() int {
+	return p.workers
+}
+
+// Stream runs the jobs and sends each result as soon as its job finishes, so the order can change between runs
+// The channel is closed after the last result, the caller must read until then or the workers stay blocked
+// Jobs still queued once ctx is done are not started, their Err is context.Cause(ctx)
+func (p *Pool[J, R]) Stream(ctx context.Context, jobs []J) <-chan Result[J, R] {
+	queue := make(chan int, p.workers) // the job queue holds indexes, it only needs to keep every worker busy
+	results := make(chan Result[J, R], p.workers)
+
+	go func() {
+		defer close(queue) // a closed queue ends the workers' range loops
+		for i := range jobs {
+			queue <- i // blocks while the queue is full, so the jobs are not all queued up front
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range min(p.workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				r := Result[J, R]{Index: i, Job: jobs[i]}
+				if ctx.Err() != nil {
+					r.Err = context.Cause(ctx)
+				} else {
+					r.Value, r.Err = p.do(ctx, jobs[i])
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// Run waits for every job and returns the results in job order, results[i] belongs to jobs[i]
+func (p *Pool[J, R]) Run(ctx context.Context, jobs []J) []Result[J, R] {
+	results := make([]Result[J, R], len(jobs))
+	for r := range p.Stream(ctx, jobs) {
+		results[r.Index] = r
+	}
+	return results
+}
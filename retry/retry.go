@@ -0,0 +1,74 @@
+// Package retry calls a failing function again, waiting longer after each failure
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+)
+
+/*
+
+	Exponential backoff with jitter
+
+	Retrying straight away hammers a service that is already struggling, so each wait is Multiplier times the last:
+	100ms, 200ms, 400ms, ... up to Max
+	Jitter makes part of every wait random, so callers that failed together do not all retry at the same moment
+
+*/
+
+// Policy says how often and how patiently Do retries
+type Policy struct {
+	Attempts   int              // calls in total, the first one included; less than 1 means 1
+	Base       time.Duration    // wait after the first failure
+	Max        time.Duration    // longest single wait, 0 for no limit
+	Multiplier float64          // how much each wait grows, 0 means 2
+	Jitter     float64          // fraction of each wait that is random, from 0 (none) to 1 (anything up to the full wait)
+	Retryable  func(error) bool // reports whether an error is worth retrying, nil retries every error
+}
+
+// maxWait is the largest float64 below 1<<63, float64(math.MaxInt64) rounds up to 1<<63 which no longer fits a Duration
+const maxWait = float64(math.MaxInt64 - 1023)
+
+// Backoff returns the wait after the nth failed attempt, n starts at 1
+// rng supplies the jitter, a nil rng leaves the wait unjittered
+func (p Policy) Backoff(n int, rng *rand.Rand) time.Duration {
+	if p.Base <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	wait := float64(p.Base) * math.Pow(multiplier, float64(n-1))
+	if p.Max > 0 && wait > float64(p.Max) {
+		wait = float64(p.Max)
+	}
+	// Without Max the wait outgrows a Duration after enough attempts and math.Pow ends at +Inf,
+	// converting such a float to a Duration is implementation-defined, so the wait stops just short of the longest Duration
+	wait = min(wait, maxWait)
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 && rng != nil {
+		wait -= wait * jitter * rng.Float64() // the random part is taken off, so a wait never exceeds Max
+	}
+	return time.Duration(wait)
+}
+
+// Do calls fn until it succeeds, fails with an error the policy does not retry, or has been called Attempts times
+// It waits on c between attempts and returns the number of calls made with the last error
+// If ctx is done during a wait, the error wraps both the context's cause and fn's last error
+func Do(ctx context.Context, c clock.Clock, rng *rand.Rand, p Policy, fn func(ctx context.Context) error) (int, error) {
+	attempts := max(p.Attempts, 1)
+	for n := 1; ; n++ {
+		err := fn(ctx)
+		if err == nil || n == attempts || (p.Retryable != nil && !p.Retryable(err)) {
+			return n, err
+		}
+		if werr := clock.SleepContext(ctx, c, p.Backoff(n, rng)); werr != nil {
+			return n, fmt.Errorf("retry: %w while waiting after attempt %d: %w", werr, n, err)
+		}
+	}
+}
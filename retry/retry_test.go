@@ -0,0 +1,109 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/donnebaldemeca/GoBasics/clock"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		n      int
+		want   time.Duration
+	}{
+		{"first wait", Policy{Base: 100 * time.Millisecond}, 1, 100 * time.Millisecond},
+		{"doubles by default", Policy{Base: 100 * time.Millisecond}, 4, 800 * time.Millisecond},
+		{"multiplier", Policy{Base: time.Second, Multiplier: 3}, 3, 9 * time.Second},
+		{"capped by Max", Policy{Base: 100 * time.Millisecond, Max: time.Second}, 10, time.Second},
+		{"no Base", Policy{Max: time.Second}, 3000, 0},
+		{"past the longest Duration", Policy{Base: time.Second}, 100, time.Duration(maxWait)},
+		{"math.Pow overflows", Policy{Base: time.Second}, 3000, time.Duration(maxWait)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.n, nil); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, p := range []Policy{
+		{Base: 100 * time.Millisecond, Max: time.Second, Jitter: 0.5},
+		{Base: time.Second, Jitter: 1}, // no Max, the overflowing waits are jittered too
+	} {
+		for n := 1; n <= 2000; n++ {
+			full := p
+			full.Jitter = 0
+			top := full.Backoff(n, nil)
+			got := p.Backoff(n, rng)
+			if got < 0 || got > top || float64(got) < float64(top)*(1-p.Jitter)-1 {
+				t.Fatalf("%+v: Backoff(%d) = %v, want between %v and %v", p, n, got, time.Duration(float64(top)*(1-p.Jitter)), top)
+			}
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	errFatal := errors.New("fatal")
+	p := Policy{Attempts: 4, Base: 100 * time.Millisecond, Retryable: func(err error) bool { return errors.Is(err, errFlaky) }}
+
+	tests := []struct {
+		name         string
+		errs         []error // what each call returns, nil once they run out
+		wantAttempts int
+		wantErr      error
+		wantWaited   time.Duration
+	}{
+		{"first try", nil, 1, nil, 0},
+		{"succeeds on the third", []error{errFlaky, errFlaky}, 3, nil, 300 * time.Millisecond},
+		{"not retryable", []error{errFlaky, errFatal}, 2, errFatal, 100 * time.Millisecond},
+		{"out of attempts", []error{errFlaky, errFlaky, errFlaky, errFlaky, errFlaky}, 4, errFlaky, 700 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewAuto(time.Unix(0, 0))
+			start := c.Now()
+			calls := 0
+			attempts, err := Do(context.Background(), c, nil, p, func(context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("attempts = %d after %d calls, want %d", attempts, calls, tt.wantAttempts)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if waited := c.Since(start); waited != tt.wantWaited {
+				t.Errorf("waited %v, want %v", waited, tt.wantWaited)
+			}
+		})
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	c := clock.NewAuto(time.Unix(0, 0))
+	ctx, cancel := clock.WithTimeout(context.Background(), c, 250*time.Millisecond)
+	defer cancel()
+	errFlaky := errors.New("flaky")
+	attempts, err := Do(ctx, c, nil, Policy{Attempts: 10, Base: 100 * time.Millisecond}, func(context.Context) error { return errFlaky })
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2: the wait after the second call outlasts the deadline", attempts)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errFlaky) {
+		t.Errorf("err = %v, want it to wrap both the deadline and the last error", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package stats has generic helpers that summarise slices of numbers
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var ErrEmpty = errors.New("stats: no values")
+
+// Number is every integer and floating point type, and types defined on them
+// The ~ means "this type or any type whose underlying type it is", so a type like `type MPG int` fits as well
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Sum adds up xs, it is the generics lesson's sumSlice taking any Number instead of only int | float32 | float64
+func Sum[T Number](xs []T) T {
+	var sum T
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+// Mean is a float64 even for integers, the mean of 1 and 2 is 1.5
+func Mean[T Number](xs []T) (float64, error) {
+	if len(xs) == 0 {
+		return 0, ErrEmpty
+	}
+	var sum float64 // summing as float64 cannot overflow a small integer type
+	for _, x := range xs {
+		sum += float64(x)
+	}
+	return sum / float64(len(xs)), nil
+}
+
+// MinMax returns the smallest and largest value
+func MinMax[T Number](xs []T) (T, T, error) {
+	if len(xs) == 0 {
+		var zero T
+		return zero, zero, ErrEmpty
+	}
+	return slices.Min(xs), slices.Max(xs), nil
+}
+
+// Median is the middle value, or the mean of the two middle values for an even count
+func Median[T Number](xs []T) (float64, error) {
+	return Percentile(xs, 50)
+}
+
+// Percentile returns the value below which p percent of xs fall, interpolating between neighbours
+// Percentile(xs, 0) is the minimum and Percentile(xs, 100) the maximum; xs is not changed
+func Percentile[T Number](xs []T, p float64) (float64, error) {
+	if len(xs) == 0 {
+		return 0, ErrEmpty
+	}
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return 0, fmt.Errorf("stats: percentile %g is outside 0 to 100", p)
+	}
+	sorted := slices.Clone(xs)
+	slices.Sort(sorted)
+	return percentile(sorted, p), nil
+}
+
+// percentile expects sorted, non-empty xs
+func percentile[T Number](sorted []T, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	weight := rank - float64(lower)
+	return float64(sorted[lower])*(1-weight) + float64(sorted[upper])*weight
+}
+
+// Summary describes a slice of numbers, Total, Min and Max keep the type of the values
+type Summary[T Number] struct {
+	Count  int     `json:"count"`
+	Total  T       `json:"total"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Min    T       `json:"min"`
+	Max    T       `json:"max"`
+	P10    float64 `json:"p10"` // 80% of the values lie between P10 and P90
+	P90    float64 `json:"p90"`
+}
+
+// Summarize computes every field of a Summary, sorting a copy of xs once
+func Summarize[T Number](xs []T) (Summary[T], error) {
+	if len(xs) == 0 {
+		return Summary[T]{}, ErrEmpty
+	}
+	sorted := slices.Clone(xs)
+	slices.Sort(sorted)
+	mean, _ := Mean(sorted)
+	return Summary[T]{
+		Count:  len(sorted),
+		Total:  Sum(sorted),
+		Mean:   mean,
+		Median: percentile(sorted, 50),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		P10:    percentile(sorted, 10),
+		P90:    percentile(sorted, 90),
+	}, nil
+}
+
+func (s Summary[T]) String() string {
+	return fmt.Sprintf("n=%d total=%v mean=%.2f median=%.2f min=%v max=%v p10-p90=%.2f-%.2f",
+		s.Count, s.Total, s.Mean, s.Median, s.Min, s.Max, s.P10, s.P90)
+}
@@ -0,0 +1,166 @@
+// Package store is a small key-value database for the go routine demos, kept in memory or in a file
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+var ErrNotFound = errors.New("store: key not found")
+
+// Store is what the demos need from a database, every implementation is safe to use from several go routines
+type Store interface {
+	Get(key string) (string, error) // ErrNotFound when the key is missing
+	Put(key, value string) error
+	List() ([]string, error) // every key, sorted
+	Delete(key string) error // ErrNotFound when the key is missing
+}
+
+// Memory keeps its data in a map
+// A map must not be written by two go routines at once, so every method takes the lock first:
+// the mutex that used to sit next to the demo's global slices now lives inside the store that needs it
+type Memory struct {
+	mu   sync.RWMutex // readers share the lock, a writer has it to itself
+	data map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{data: make(map[string]string)}
+}
+
+func (m *Memory) Get(key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	return value, nil
+}
+
+func (m *Memory) Put(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *Memory) List() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Sorted(maps.Keys(m.data)), nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; !ok {
+		return fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	delete(m.data, key)
+	return nil
+}
+
+// File keeps its data in a JSON object on disk, every call reads the file and every change rewrites it
+// The lock only covers this process, two programs sharing one file can still overwrite each other's changes
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a store backed by path, the file is created on the first Put
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	return value, nil
+}
+
+func (f *File) Put(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+	data[key] = value
+	return f.save(data)
+}
+
+func (f *File) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(data)), nil
+}
+
+func (f *File) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := data[key]; !ok {
+		return fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	delete(data, key)
+	return f.save(data)
+}
+
+// load must be called with f.mu held, a missing file is an empty store
+func (f *File) load() (map[string]string, error) {
+	data := make(map[string]string)
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("store: reading %s: %w", f.path, err)
+	}
+	return data, nil
+}
+
+// save must be called with f.mu held, it renames a temporary file into place so readers never see half a file
+func (f *File) save(data map[string]string) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename succeeded
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// implementations returns a fresh store of every kind, File stores get their own temporary directory
+func implementations(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"Memory": NewMemory(),
+		"File":   NewFile(filepath.Join(t.TempDir(), "db.json")),
+	}
+}
+
+func TestStore(t *testing.T) {
+	for name, s := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get on an empty store: %v, want ErrNotFound", err)
+			}
+			if err := s.Delete("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Delete on an empty store: %v, want ErrNotFound", err)
+			}
+			for _, kv := range [][2]string{{"b", "2"}, {"a", "1"}, {"a", "one"}} {
+				if err := s.Put(kv[0], kv[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if v, err := s.Get("a"); err != nil || v != "one" {
+				t.Errorf(`Get("a") = %q, %v, want the last value "one"`, v, err)
+			}
+			if keys, err := s.List(); err != nil || !slices.Equal(keys, []string{"a", "b"}) {
+				t.Errorf("List() = %v, %v, want [a b]", keys, err)
+			}
+			if err := s.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// TestConcurrent has several go routines Get, Put and Delete their own keys while all of them read a shared one
+// Run it with go test -race ./store, the race detector reports any access the locks do not cover
+func TestConcurrent(t *testing.T) {
+	const workers, rounds = 8, 24
+	for name, s := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if err := s.Put("shared", "v"); err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			errs := make(chan error, workers*rounds)
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range rounds {
+						key := fmt.Sprintf("w%d-%d", w, i)
+						if err := s.Put(key, key); err != nil {
+							errs <- err
+							continue
+						}
+						if v, err := s.Get(key); err != nil || v != key {
+							errs <- fmt.Errorf("Get(%q) = %q, %v", key, v, err)
+						}
+						if v, err := s.Get("shared"); err != nil || v != "v" {
+							errs <- fmt.Errorf(`Get("shared") = %q, %v`, v, err)
+						}
+						if i%2 == 1 { // every other key is deleted again, the rest must survive
+							if err := s.Delete(key); err != nil {
+								errs <- err
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			keys, err := s.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 1 + workers*rounds/2; len(keys) != want {
+				t.Errorf("%d keys left, want %d", len(keys), want)
+			}
+		})
+	}
+}
+
+// TestFileReopen checks that the data is in the file and not only in the File value that wrote it
+func TestFileReopen(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	first := NewFile(path)
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"c", "3"}} {
+		if err := first.Put(kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := first.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewFile(path)
+	if keys, err := reopened.List(); err != nil || !slices.Equal(keys, []string{"a", "c"}) {
+		t.Errorf("List() after reopening = %v, %v, want [a c]", keys, err)
+	}
+	if v, err := reopened.Get("c"); err != nil || v != "3" {
+		t.Errorf(`Get("c") after reopening = %q, %v, want "3"`, v, err)
+	}
+
+	// Every temporary file was renamed into place or removed, only the store itself is left
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "db.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want only db.json", names)
+	}
+}
+
+func TestFileCorrupt(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path).Get("a"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get on a corrupt file: %v, want a read error", err)
+	}
+}
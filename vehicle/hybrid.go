@@ -0,0 +1,166 @@
+package vehicle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// DrainOrder decides which part of a hybrid a trip uses first
+type DrainOrder uint8
+
+const (
+	ElectricFirst DrainOrder = iota // drive on the battery, then switch to gas
+	GasFirst                        // drive on gas, keep the battery for later
+	Blended                         // use both at once, so they run out together
+)
+
+func (o DrainOrder) String() string {
+	switch o {
+	case ElectricFirst:
+		return "electric-first"
+	case GasFirst:
+		return "gas-first"
+	case Blended:
+		return "blended"
+	}
+	return fmt.Sprintf("DrainOrder(%d)", uint8(o))
+}
+
+// ParseDrainOrder is the reverse of DrainOrder.String
+func ParseDrainOrder(s string) (DrainOrder, error) {
+	for _, o := range []DrainOrder{ElectricFirst, GasFirst, Blended} {
+		if o.String() == s {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("vehicle: unknown drain order %q (want electric-first, gas-first or blended)", s)
+}
+
+// MarshalText and UnmarshalText make the drain order read as its name in JSON
+func (o DrainOrder) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+func (o *DrainOrder) UnmarshalText(text []byte) error {
+	parsed, err := ParseDrainOrder(string(text))
+	if err != nil {
+		return err
+	}
+	*o = parsed
+	return nil
+}
+
+// HybridEngine is a plug-in hybrid made of a gas and an electric engine
+// Both are embedded, so their fields are promoted: h.MPG, h.Gallons, h.MPKWh and h.KWh all work
+// Both embedded types also have MilesLeft and OwnerInfo, the ones declared on HybridEngine itself win
+// In JSON a hybrid is one flat object: {"mpg":40,"gallons":10,"mpkwh":3,"kwh":12,"order":"electric-first"}
+type HybridEngine struct {
+	GasEngine      `json:"-"`
+	ElectricEngine `json:"-"`
+	Order          DrainOrder  `json:"order"`
+	OwnerInfo      EngineOwner `json:"owner"`
+}
+
+type hybridJSON struct {
+	MPG         float64     `json:"mpg"`
+	Gallons     float64     `json:"gallons"`
+	TankGallons float64     `json:"tank_gallons,omitempty"`
+	MPKWh       float64     `json:"mpkwh"`
+	KWh         float64     `json:"kwh"`
+	BatteryKWh  float64     `json:"battery_kwh,omitempty"`
+	Order       DrainOrder  `json:"order"`
+	OwnerInfo   EngineOwner `json:"owner"`
+}
+
+func (h HybridEngine) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hybridJSON{
+		MPG: h.MPG, Gallons: h.Gallons, TankGallons: h.TankGallons,
+		MPKWh: h.MPKWh, KWh: h.KWh, BatteryKWh: h.BatteryKWh,
+		Order: h.Order, OwnerInfo: h.OwnerInfo,
+	})
+}
+
+// UnmarshalJSON rejects fields a hybrid does not have, a custom UnmarshalJSON does not inherit
+// DisallowUnknownFields from the decoder that called it, so it has to ask for it again
+func (h *HybridEngine) UnmarshalJSON(data []byte) error {
+	var in hybridJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		return err
+	}
+	*h = HybridEngine{
+		GasEngine:      GasEngine{MPG: in.MPG, Gallons: in.Gallons, TankGallons: in.TankGallons},
+		ElectricEngine: ElectricEngine{MPKWh: in.MPKWh, KWh: in.KWh, BatteryKWh: in.BatteryKWh},
+		Order:          in.Order,
+		OwnerInfo:      in.OwnerInfo,
+	}
+	return nil
+}
+
+// MilesLeft is the combined range of both parts, the drain order does not change it
+func (h HybridEngine) MilesLeft() (float64, error) {
+	gas, err := h.GasEngine.MilesLeft()
+	if err != nil {
+		return 0, err
+	}
+	electric, err := h.ElectricEngine.MilesLeft()
+	if err != nil {
+		return 0, err
+	}
+	if math.IsInf(gas+electric, 0) {
+		return 0, fmt.Errorf("%w: %g + %g", ErrOverflow, gas, electric)
+	}
+	return gas + electric, nil
+}
+
+// Draw splits a trip between gas and electricity according to the drain order
+func (h HybridEngine) Draw(miles float64) ([]Draw, error) {
+	gas, err := h.GasEngine.MilesLeft()
+	if err != nil {
+		return nil, err
+	}
+	electric, err := h.ElectricEngine.MilesLeft()
+	if err != nil {
+		return nil, err
+	}
+	miles = min(max(miles, 0), gas+electric)
+
+	var onGas, onElectric float64
+	switch h.Order {
+	case ElectricFirst:
+		onElectric = min(miles, electric)
+		onGas = miles - onElectric
+	case GasFirst:
+		onGas = min(miles, gas)
+		onElectric = miles - onGas
+	case Blended:
+		if gas+electric > 0 {
+			onGas = miles * gas / (gas + electric)
+			onElectric = miles - onGas
+		}
+	default:
+		return nil, fmt.Errorf("vehicle: unknown drain order %v", h.Order)
+	}
+
+	var draws []Draw
+	for _, part := range []struct {
+		drawer Drawer
+		miles  float64
+	}{{h.ElectricEngine, onElectric}, {h.GasEngine, onGas}} {
+		if part.miles <= 0 {
+			continue
+		}
+		d, err := part.drawer.Draw(part.miles)
+		if err != nil {
+			return nil, err
+		}
+		draws = append(draws, d...)
+	}
+	if h.Order == GasFirst && len(draws) == 2 {
+		draws[0], draws[1] = draws[1], draws[0] // report in the order the sources are used
+	}
+	return draws, nil
+}
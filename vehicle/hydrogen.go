@@ -0,0 +1,18 @@
+package vehicle
+
+// HydrogenEngine is a fuel cell engine, it turns compressed hydrogen into electricity as it drives
+// Its consumption is measured in miles per kilogram of hydrogen, a full tank holds a few kilograms
+type HydrogenEngine struct {
+	MilesPerKg float64     `json:"miles_per_kg"`
+	Kg         float64     `json:"kg"`                // hydrogen currently in the tank
+	TankKg     float64     `json:"tank_kg,omitempty"` // tank size, 0 means unknown and refuelling is unlimited
+	OwnerInfo  EngineOwner `json:"owner"`
+}
+
+func (h HydrogenEngine) MilesLeft() (float64, error) {
+	return milesFrom(h.MilesPerKg, h.Kg)
+}
+
+func (h HydrogenEngine) Draw(miles float64) ([]Draw, error) {
+	return drawFrom(Hydrogen, h.MilesPerKg, h.Kg, miles)
+}
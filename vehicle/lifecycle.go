@@ -0,0 +1,266 @@
+package vehicle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var (
+	ErrRanDry       = errors.New("vehicle: not enough energy for the trip")
+	ErrOverCapacity = errors.New("vehicle: more than the tank or battery holds")
+	ErrNoCapacity   = errors.New("vehicle: tank or battery size is unknown")
+	ErrWrongSource  = errors.New("vehicle: engine does not run on that source")
+	ErrInvalidFill  = errors.New("vehicle: fill amount must be a finite number")
+)
+
+/*
+
+	Refuelling, recharging and driving change the engine, so these methods have pointer receivers
+	A method with a value receiver works on a copy, changes to the copy would be lost when it returns
+
+	Only *GasEngine has the Drive method, not GasEngine, so an interface holding a GasEngine value
+	does not satisfy Driver while one holding a *GasEngine does
+
+*/
+
+// Driver is implemented by engines whose energy goes down as they drive
+// Drive fails with ErrRanDry and leaves the engine unchanged if the trip is longer than the range
+type Driver interface {
+	Engine
+	Drive(miles float64) error
+}
+
+// Capacity is implemented by engines that know how far they go on full tanks
+// MaxMiles only reads the engine, so it has a value receiver and works on engine values too
+type Capacity interface {
+	MaxMiles() (float64, error) // range with every tank and battery full, +Inf when a size is unknown
+}
+
+// Refillable is implemented by engines that can take on energy
+// Fill adds amount of source, FillUp fills every tank and battery to its capacity
+type Refillable interface {
+	Engine
+	Capacity
+	Fill(source Source, amount float64) error
+	FillUp() error
+}
+
+// tank is the shared bookkeeping of a tank or battery
+type tank struct {
+	level    *float64
+	capacity float64
+	source   Source
+}
+
+func (t tank) fill(amount float64) error {
+	if !finite(amount) { // a NaN level would spread into every later range, plan and cost
+		return fmt.Errorf("%w: %g %s", ErrInvalidFill, amount, t.source.Unit())
+	}
+	if amount < 0 {
+		return ErrNegative
+	}
+	if t.capacity <= 0 {
+		*t.level += amount
+		return nil
+	}
+	if short(t.capacity, *t.level+amount) {
+		return fmt.Errorf("%w: %g + %g %s is more than %g", ErrOverCapacity, *t.level, amount, t.source.Unit(), t.capacity)
+	}
+	*t.level = min(*t.level+amount, t.capacity) // filling the room left can overshoot by a rounding error
+	return nil
+}
+
+// slot describes the tank for the trip planner, efficiency is in miles per unit
+func (t tank) slot(efficiency float64) refillSlot {
+	room := math.Inf(1)
+	if t.capacity > 0 {
+		room = max(t.capacity-*t.level, 0)
+	}
+	return refillSlot{source: t.source, efficiency: efficiency, room: room}
+}
+
+// tolerance absorbs rounding when energy is turned into miles and back, e.g. a trip that uses exactly the range left
+const tolerance = 1e-9
+
+// short reports whether have falls short of want by more than a rounding error
+func short(have, want float64) bool {
+	return have < want-tolerance*max(1, math.Abs(want))
+}
+
+func (t tank) fillUp() error {
+	if t.capacity <= 0 {
+		return fmt.Errorf("%w: %s", ErrNoCapacity, t.source)
+	}
+	*t.level = t.capacity
+	return nil
+}
+
+// use takes amount out of the tank, rounding errors must never leave a tiny negative level behind
+func (t tank) use(amount float64) {
+	*t.level = max(*t.level-amount, 0)
+}
+
+func maxMiles(efficiency, capacity float64) (float64, error) {
+	if capacity <= 0 {
+		return math.Inf(1), nil
+	}
+	return milesFrom(efficiency, capacity)
+}
+
+// drive checks the trip against the engine's draws, then takes each draw out of its tank
+func drive(e Drawer, miles float64, tanks ...tank) error {
+	if err := CheckTrip(miles); err != nil {
+		return err
+	}
+	draws, err := e.Draw(miles)
+	if err != nil {
+		return err
+	}
+	covered := 0.0
+	for _, d := range draws {
+		covered += d.Miles
+	}
+	if short(covered, miles) {
+		return fmt.Errorf("%w: %g miles asked, %g miles left", ErrRanDry, miles, covered)
+	}
+	for _, d := range draws {
+		for _, t := range tanks {
+			if t.source == d.Source {
+				t.use(d.Amount)
+			}
+		}
+	}
+	return nil
+}
+
+func fillSource(source Source, amount float64, tanks ...tank) error {
+	for _, t := range tanks {
+		if t.source == source {
+			return t.fill(amount)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrWrongSource, source)
+}
+
+func (g *GasEngine) tank() tank { return tank{&g.Gallons, g.TankGallons, Gasoline} }
+
+func (g *GasEngine) Drive(miles float64) error { return drive(g, miles, g.tank()) }
+
+func (g *GasEngine) Fill(source Source, gallons float64) error {
+	return fillSource(source, gallons, g.tank())
+}
+
+func (g *GasEngine) FillUp() error               { return g.tank().fillUp() }
+func (g *GasEngine) slots() ([]refillSlot, bool) { return []refillSlot{g.tank().slot(g.MPG)}, false }
+func (g GasEngine) MaxMiles() (float64, error)   { return maxMiles(g.MPG, g.TankGallons) }
+
+func (e *ElectricEngine) tank() tank                { return tank{&e.KWh, e.BatteryKWh, Electricity} }
+func (e *ElectricEngine) Drive(miles float64) error { return drive(e, miles, e.tank()) }
+
+// Recharge adds kWh to the battery, a partial charge
+func (e *ElectricEngine) Recharge(kWh float64) error { return e.tank().fill(kWh) }
+
+func (e *ElectricEngine) Fill(source Source, kWh float64) error {
+	return fillSource(source, kWh, e.tank())
+}
+
+func (e *ElectricEngine) FillUp() error { return e.tank().fillUp() }
+func (e *ElectricEngine) slots() ([]refillSlot, bool) {
+	return []refillSlot{e.tank().slot(e.MPKWh)}, false
+}
+func (e ElectricEngine) MaxMiles() (float64, error) { return maxMiles(e.MPKWh, e.BatteryKWh) }
+
+func (h *HydrogenEngine) tank() tank                { return tank{&h.Kg, h.TankKg, Hydrogen} }
+func (h *HydrogenEngine) Drive(miles float64) error { return drive(h, miles, h.tank()) }
+func (h *HydrogenEngine) FillUp() error             { return h.tank().fillUp() }
+func (h *HydrogenEngine) slots() ([]refillSlot, bool) {
+	return []refillSlot{h.tank().slot(h.MilesPerKg)}, false
+}
+func (h HydrogenEngine) MaxMiles() (float64, error) { return maxMiles(h.MilesPerKg, h.TankKg) }
+
+func (h *HydrogenEngine) Fill(source Source, kg float64) error {
+	return fillSource(source, kg, h.tank())
+}
+
+// Drive uses the hybrid's drain order to decide how much comes out of the tank and how much out of the battery
+func (h *HybridEngine) Drive(miles float64) error {
+	return drive(h, miles, h.GasEngine.tank(), h.ElectricEngine.tank())
+}
+
+func (h *HybridEngine) Fill(source Source, amount float64) error {
+	return fillSource(source, amount, h.GasEngine.tank(), h.ElectricEngine.tank())
+}
+
+// slots lists the battery and the tank in the order the drain order uses them, a blended hybrid shares a refill between them
+func (h *HybridEngine) slots() ([]refillSlot, bool) {
+	gas := h.GasEngine.tank().slot(h.MPG)
+	electric := h.ElectricEngine.tank().slot(h.MPKWh)
+	if h.Order == GasFirst {
+		return []refillSlot{gas, electric}, false
+	}
+	return []refillSlot{electric, gas}, h.Order == Blended
+}
+
+// FillUp fills both the tank and the battery, it fails without changing anything if either size is unknown
+func (h *HybridEngine) FillUp() error {
+	if h.TankGallons <= 0 || h.BatteryKWh <= 0 {
+		return fmt.Errorf("%w: hybrid needs both tank_gallons and battery_kwh", ErrNoCapacity)
+	}
+	h.Gallons, h.KWh = h.TankGallons, h.BatteryKWh
+	return nil
+}
+
+func (h HybridEngine) MaxMiles() (float64, error) {
+	gas, err := h.GasEngine.MaxMiles()
+	if err != nil {
+		return 0, err
+	}
+	electric, err := h.ElectricEngine.MaxMiles()
+	if err != nil {
+		return 0, err
+	}
+	return gas + electric, nil
+}
+
+// Refuel returns a copy of e with amount of source added, or with every tank and battery full when amount is 0
+// e can be an engine value, like the engines kept in a fleet: the pointer methods run on a copy and
+// the result has the same type as e, so e itself never changes
+func Refuel(e Engine, source Source, amount float64) (Engine, error) {
+	ptr, result := mutableCopy(e)
+	r, ok := ptr.(Refillable)
+	if !ok {
+		return nil, fmt.Errorf("vehicle: %T cannot be refuelled", e)
+	}
+	var err error
+	if amount == 0 {
+		err = r.FillUp()
+	} else {
+		err = r.Fill(source, amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result(), nil
+}
+
+// mutableCopy returns a pointer to a copy of e and a function giving the copy back in e's own form, value or pointer
+func mutableCopy(e Engine) (any, func() Engine) {
+	v := reflect.ValueOf(e)
+	isPointer := v.Kind() == reflect.Pointer
+	if !v.IsValid() || isPointer && v.IsNil() {
+		return nil, nil // nothing to copy, the caller's type assertion fails
+	}
+	if isPointer {
+		v = v.Elem()
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Interface(), func() Engine {
+		if isPointer {
+			return ptr.Interface().(Engine)
+		}
+		return ptr.Elem().Interface().(Engine)
+	}
+}
@@ -0,0 +1,51 @@
+package vehicle
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	g := &GasEngine{MPG: 25, Gallons: 4, TankGallons: 15}
+	if err := g.Fill(Gasoline, 11); err != nil || g.Gallons != 15 {
+		t.Errorf("Fill(11) = %v, %v gallons, want a full 15", err, g.Gallons)
+	}
+	if err := g.Fill(Gasoline, 0.5); !errors.Is(err, ErrOverCapacity) {
+		t.Errorf("Fill past the tank size = %v, want ErrOverCapacity", err)
+	}
+	if err := g.Fill(Electricity, 1); !errors.Is(err, ErrWrongSource) {
+		t.Errorf("Fill(Electricity) = %v, want ErrWrongSource", err)
+	}
+	if err := g.Fill(Gasoline, -1); !errors.Is(err, ErrNegative) {
+		t.Errorf("Fill(-1) = %v, want ErrNegative", err)
+	}
+}
+
+// TestFillNotFinite checks that NaN and infinite amounts are rejected and leave the engine unchanged,
+// also for tanks of unknown size, which take any finite amount
+func TestFillNotFinite(t *testing.T) {
+	engines := []struct {
+		name   string
+		engine Refillable
+		source Source
+	}{
+		{"gas", &GasEngine{MPG: 25, Gallons: 4, TankGallons: 15}, Gasoline},
+		{"gas without a tank size", &GasEngine{MPG: 25, Gallons: 4}, Gasoline},
+		{"electric", &ElectricEngine{MPKWh: 3, KWh: 10}, Electricity},
+		{"hydrogen", &HydrogenEngine{MilesPerKg: 60, Kg: 1}, Hydrogen},
+		{"hybrid gas", &HybridEngine{GasEngine: GasEngine{MPG: 40, Gallons: 1}, ElectricEngine: ElectricEngine{MPKWh: 3, KWh: 1}}, Gasoline},
+		{"hybrid electric", &HybridEngine{GasEngine: GasEngine{MPG: 40, Gallons: 1}, ElectricEngine: ElectricEngine{MPKWh: 3, KWh: 1}}, Electricity},
+	}
+	for _, tt := range engines {
+		for _, amount := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			before, _ := tt.engine.MilesLeft()
+			if err := tt.engine.Fill(tt.source, amount); !errors.Is(err, ErrInvalidFill) {
+				t.Errorf("%s: Fill(%g) = %v, want ErrInvalidFill", tt.name, amount, err)
+			}
+			if after, err := tt.engine.MilesLeft(); err != nil || after != before {
+				t.Errorf("%s: range went from %g to %g (%v) after a rejected Fill(%g)", tt.name, before, after, err, amount)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package vehicle
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+)
+
+var ErrIDsExhausted = errors.New("vehicle: no owner IDs left")
+
+type EngineOwner struct {
+	Name    string `json:"name"`
+	OwnerID        // embedded struct, its fields are promoted so owner.ID works as well as owner.OwnerID.ID
+}
+
+// String is declared so fmt does not fall back to the String method promoted from OwnerID
+func (o EngineOwner) String() string {
+	return fmt.Sprintf("%s (ID %v)", o.Name, o.OwnerID)
+}
+
+// OwnerID identifies an owner by a sequential number, a random UUID, or both
+// The zero OwnerID means "no ID yet"
+type OwnerID struct {
+	ID   uint64 `json:"id"`
+	UUID string `json:"uuid,omitempty"`
+}
+
+func (id OwnerID) String() string {
+	if id.UUID != "" {
+		return id.UUID
+	}
+	return strconv.FormatUint(id.ID, 10)
+}
+
+func (id OwnerID) IsZero() bool {
+	return id == OwnerID{}
+}
+
+// ParseOwnerID reads an ID written by OwnerID.String, a number or a UUID
+func ParseOwnerID(s string) (OwnerID, error) {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return OwnerID{ID: n}, nil
+	}
+	if !IsUUID(s) {
+		return OwnerID{}, fmt.Errorf("vehicle: owner ID %q is neither a number nor a UUID", s)
+	}
+	return OwnerID{UUID: s}, nil
+}
+
+// IDAllocator issues owner IDs
+// Allocators only promise not to repeat themselves, fleet.Registry checks the IDs against the owners it already has
+type IDAllocator interface {
+	NextID() (OwnerID, error)
+}
+
+// SequentialIDs issues 1, 2, 3 and so on, it is safe to use from several go routines
+type SequentialIDs struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+// NewSequentialIDs returns an allocator whose first ID is last+1
+func NewSequentialIDs(last uint64) *SequentialIDs {
+	return &SequentialIDs{last: last}
+}
+
+func (s *SequentialIDs) NextID() (OwnerID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == math.MaxUint64 {
+		return OwnerID{}, ErrIDsExhausted
+	}
+	s.last++
+	return OwnerID{ID: s.last}, nil
+}
+
+// RandomIDs issues random version 4 UUIDs such as "0b6c1b9e-7c5d-4f6e-9a3b-2d1e0f4c5b6a"
+// Rand is the source of randomness, crypto/rand when nil
+type RandomIDs struct {
+	Rand io.Reader
+}
+
+func (r RandomIDs) NextID() (OwnerID, error) {
+	src := r.Rand
+	if src == nil {
+		src = rand.Reader
+	}
+	var b [16]byte
+	if _, err := io.ReadFull(src, b[:]); err != nil {
+		return OwnerID{}, err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return OwnerID{UUID: fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])}, nil
+}
+
+// IsUUID reports whether s is a UUID in the lower case form RandomIDs issues, ParseOwnerID also accepts numbers
+func IsUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package vehicle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+var ErrBadRoute = errors.New("vehicle: invalid route")
+
+// Stop is a place to refuel or recharge along a route
+// At is the waypoint the stop is at: 0 is the start, i is the end of leg i
+type Stop struct {
+	Name    string   `json:"name"`
+	At      int      `json:"at"`
+	Sources []Source `json:"sources,omitempty"` // what the stop sells, empty means everything
+}
+
+func (s Stop) sells(source Source) bool {
+	return len(s.Sources) == 0 || slices.Contains(s.Sources, source)
+}
+
+// sellsAll reports whether the stop sells every source of draws
+func (s Stop) sellsAll(draws []Draw) bool {
+	for _, d := range draws {
+		if !s.sells(d.Source) {
+			return false
+		}
+	}
+	return true
+}
+
+// refillSlot is one tank or battery as the planner sees it
+type refillSlot struct {
+	source     Source
+	efficiency float64 // miles per unit
+	room       float64 // units it still takes, +Inf when its size is unknown
+}
+
+// slotter is implemented by the built in engines, slots lists their tanks and batteries in the order a stop fills them
+// even is true when a refill is shared between the slots, as a blended hybrid shares its trips
+type slotter interface {
+	slots() (slots []refillSlot, even bool)
+}
+
+// simulated is what Plan needs to follow an engine along the route on a copy: it drives, takes on energy and lists its tanks
+type simulated interface {
+	Driver
+	Refillable
+	slotter
+}
+
+// refill works out how much of each source to add at stop for miles more range
+// No slot gets more than it holds and the rest spills over to the next slot, so the draws can come up short
+// when the stop does not sell enough of what the engine takes
+func refill(slots []refillSlot, even bool, stop Stop, miles float64) []Draw {
+	var open []refillSlot
+	for _, slot := range slots {
+		if stop.sells(slot.source) && slot.efficiency > 0 && slot.room > 0 {
+			open = append(open, slot)
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	added := make([]float64, len(open)) // miles of range added to each slot
+	need := miles
+	take := func(i int, want float64) {
+		got := min(want, open[i].room*open[i].efficiency-added[i])
+		added[i] += got
+		need -= got
+	}
+	if even {
+		share := need / float64(len(open))
+		for i := range open {
+			take(i, share)
+		}
+	}
+	for i := range open {
+		if need > 0 {
+			take(i, need)
+		}
+	}
+
+	var draws []Draw
+	for i, slot := range open {
+		if added[i] > 0 {
+			draws = append(draws, Draw{Source: slot.source, Miles: added[i], Amount: min(added[i]/slot.efficiency, slot.room)})
+		}
+	}
+	return draws
+}
+
+// Step is one line of an itinerary, either driving a leg or stopping to add energy
+type Step struct {
+	Leg       int     `json:"leg,omitempty"`  // 1 based leg number, set for driving steps
+	Stop      string  `json:"stop,omitempty"` // stop name, set for refuel steps
+	Miles     float64 `json:"miles"`          // length of the leg, or miles of range added at the stop
+	Odometer  float64 `json:"odometer"`       // miles driven since the start when the step ends
+	RangeLeft float64 `json:"range_left"`     // miles of range when the step ends
+	Added     []Draw  `json:"added,omitempty"`
+}
+
+// Itinerary is the result of planning a route
+type Itinerary struct {
+	TotalMiles float64 `json:"total_miles"`
+	StartRange float64 `json:"start_range"`
+	Steps      []Step  `json:"steps"`
+	Feasible   bool    `json:"feasible"`
+	Problem    string  `json:"problem,omitempty"` // why the trip is impossible
+}
+
+// Plan works out where e has to stop along a route of legs and how much energy to add at each stop
+// It stops only when the range left will not reach the next stop that sells what the engine needs,
+// and then adds just enough to get there, or fills up when the engine implements Capacity and the next stop is out of reach.
+// No tank or battery is given more than it holds, what does not fit goes into the engine's other sources if the stop sells them.
+// Engines that do not implement Refiller cannot use stops.
+// An impossible trip is not an error, it returns an itinerary with Feasible false and the Problem.
+func Plan(e Engine, legs []float64, stops []Stop) (Itinerary, error) {
+	for i, leg := range legs {
+		if err := CheckTrip(leg); err != nil { // NaN or infinite legs would make every comparison below meaningless
+			return Itinerary{}, fmt.Errorf("%w: leg %d: %w", ErrBadRoute, i+1, err)
+		}
+	}
+	for _, s := range stops {
+		if s.At < 0 || s.At > len(legs) {
+			return Itinerary{}, fmt.Errorf("%w: stop %q is at waypoint %d, the route has %d", ErrBadRoute, s.Name, s.At, len(legs)+1)
+		}
+	}
+	left, err := e.MilesLeft()
+	if err != nil {
+		return Itinerary{}, err
+	}
+
+	it := Itinerary{StartRange: left}
+	for _, leg := range legs {
+		it.TotalMiles += leg
+	}
+	// The built in engines are followed along the route on a copy, so each tank and battery is filled only as far as it
+	// holds and a stop is useful when it sells any source the engine takes
+	// Other engines are planned by their range alone, from the sources their EnergyFor names
+	var sim simulated
+	if ptr, _ := mutableCopy(e); ptr != nil {
+		sim, _ = ptr.(simulated)
+	}
+	refiller, canRefill := e.(Refiller)
+
+	// Keep only the stops that sell what the engine runs on, engines that cannot say keep none
+	var usable []Stop
+	switch {
+	case sim != nil:
+		slots, _ := sim.slots()
+		for _, s := range stops {
+			if slices.ContainsFunc(slots, func(slot refillSlot) bool { return s.sells(slot.source) }) {
+				usable = append(usable, s)
+			}
+		}
+	case canRefill:
+		needs, err := refiller.EnergyFor(1)
+		if err != nil {
+			return Itinerary{}, err
+		}
+		for _, s := range stops {
+			if s.sellsAll(needs) {
+				usable = append(usable, s)
+			}
+		}
+	}
+
+	maxRange := math.Inf(1)
+	if c, ok := e.(Capacity); ok {
+		if maxRange, err = c.MaxMiles(); err != nil {
+			return Itinerary{}, err
+		}
+	}
+
+	odometer := 0.0
+	for i, leg := range legs {
+		if stop, ok := stopAt(usable, i); ok {
+			if reach := min(distanceToNextStop(legs, usable, i), maxRange); short(left, reach) {
+				var draws []Draw
+				added := reach - left
+				if sim != nil {
+					slots, even := sim.slots()
+					draws = refill(slots, even, stop, added)
+					added = 0
+					for _, d := range draws {
+						if err := sim.Fill(d.Source, d.Amount); err != nil {
+							return Itinerary{}, err
+						}
+						added += d.Miles
+					}
+				} else if draws, err = refiller.EnergyFor(added); err != nil {
+					return Itinerary{}, err
+				}
+				left += added
+				if len(draws) > 0 {
+					it.Steps = append(it.Steps, Step{Stop: stop.Name, Miles: added, Odometer: odometer, RangeLeft: left, Added: draws})
+				}
+			}
+		}
+		if short(left, leg) {
+			it.Problem = fmt.Sprintf("runs out %.1f miles into leg %d", left, i+1)
+			return it, nil
+		}
+		if sim != nil {
+			if err := sim.Drive(leg); err != nil { // only the tank levels are read from the copy, left keeps the rounding out of the miles
+				return Itinerary{}, err
+			}
+		}
+		left = max(left-leg, 0)
+		odometer += leg
+		it.Steps = append(it.Steps, Step{Leg: i + 1, Miles: leg, Odometer: odometer, RangeLeft: left})
+	}
+	it.Feasible = true
+	return it, nil
+}
+
+// stopAt returns the first stop at waypoint at
+func stopAt(stops []Stop, at int) (Stop, bool) {
+	for _, s := range stops {
+		if s.At == at {
+			return s, true
+		}
+	}
+	return Stop{}, false
+}
+
+// distanceToNextStop is how far it is from waypoint from to the next stop, or to the end of the route
+func distanceToNextStop(legs []float64, stops []Stop, from int) float64 {
+	distance := 0.0
+	for i := from; i < len(legs); i++ {
+		distance += legs[i]
+		if _, ok := stopAt(stops, i+1); ok && i+1 < len(legs) {
+			break
+		}
+	}
+	return distance
+}
+
+// String prints the itinerary one step per line, in miles
+func (it Itinerary) String() string {
+	return it.Format(Imperial)
+}
+
+// Format prints the itinerary one step per line in the unit system u
+func (it Itinerary) Format(u UnitSystem) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Route of %s, starting with %s of range\n", FormatDistance(it.TotalMiles, u), FormatDistance(it.StartRange, u))
+	for _, step := range it.Steps {
+		if step.Leg > 0 {
+			fmt.Fprintf(&sb, "  leg %d: drive %s, %s driven, %s of range left\n", step.Leg, FormatDistance(step.Miles, u), FormatDistance(step.Odometer, u), FormatDistance(step.RangeLeft, u))
+			continue
+		}
+		var added []string
+		for _, d := range step.Added {
+			added = append(added, fmt.Sprintf("%s of %s", FormatAmount(d.Amount, d.Source, u), d.Source))
+		}
+		fmt.Fprintf(&sb, "  stop at %s: add %s for %s more\n", step.Stop, strings.Join(added, " and "), FormatDistance(step.Miles, u))
+	}
+	if it.Feasible {
+		sb.WriteString("Trip is possible\n")
+	} else {
+		fmt.Fprintf(&sb, "Trip is impossible: %s\n", it.Problem)
+	}
+	return sb.String()
+}
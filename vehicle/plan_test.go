@@ -0,0 +1,116 @@
+package vehicle
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// pedal is an engine the planner knows nothing about, it only has a range
+type pedal struct{ miles float64 }
+
+func (p pedal) MilesLeft() (float64, error) { return p.miles, nil }
+
+func TestPlanBadLegs(t *testing.T) {
+	engines := map[string]Engine{
+		"pedal": pedal{10},
+		"gas":   GasEngine{MPG: 25, Gallons: 4, TankGallons: 15},
+	}
+	tests := []struct {
+		name string
+		leg  float64
+		want error
+	}{
+		{"negative", -5, ErrNegativeTrip},
+		{"NaN", math.NaN(), ErrInvalidTrip},
+		{"+Inf", math.Inf(1), ErrInvalidTrip},
+		{"-Inf", math.Inf(-1), ErrInvalidTrip},
+	}
+	for kind, e := range engines {
+		for _, tt := range tests {
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				it, err := Plan(e, []float64{5, tt.leg}, nil)
+				if !errors.Is(err, ErrBadRoute) || !errors.Is(err, tt.want) {
+					t.Fatalf("Plan = %+v, %v, want ErrBadRoute wrapping %v", it, err, tt.want)
+				}
+				if !strings.Contains(err.Error(), "leg 2") {
+					t.Errorf("%q does not name the leg", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPlanStopOutsideRoute(t *testing.T) {
+	_, err := Plan(pedal{10}, []float64{5}, []Stop{{Name: "far", At: 2}})
+	if !errors.Is(err, ErrBadRoute) {
+		t.Errorf("Plan = %v, want ErrBadRoute", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	gas := GasEngine{MPG: 25, Gallons: 4, TankGallons: 15} // 100 miles now, 375 full
+	tests := []struct {
+		name     string
+		engine   Engine
+		legs     []float64
+		stops    []Stop
+		feasible bool
+		refills  int
+	}{
+		{"within range", gas, []float64{40, 50}, nil, true, 0},
+		{"no stop", gas, []float64{80, 80}, nil, false, 0},
+		{"one stop", gas, []float64{80, 80}, []Stop{{Name: "A", At: 1}}, true, 1},
+		{"stop sells the wrong source", gas, []float64{80, 80}, []Stop{{Name: "A", At: 1, Sources: []Source{Electricity}}}, false, 0},
+		{"unsimulated engine", pedal{10}, []float64{4, 5}, nil, true, 0},
+		{"unsimulated engine runs out", pedal{10}, []float64{4, 7}, nil, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, err := Plan(tt.engine, tt.legs, tt.stops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if it.Feasible != tt.feasible {
+				t.Errorf("Feasible = %v (%s), want %v", it.Feasible, it.Problem, tt.feasible)
+			}
+			if !it.Feasible && it.Problem == "" {
+				t.Error("an impossible trip has no Problem")
+			}
+			refills := 0
+			for _, step := range it.Steps {
+				if step.Stop != "" {
+					refills++
+				}
+			}
+			if refills != tt.refills {
+				t.Errorf("%d refills, want %d\n%s", refills, tt.refills, it)
+			}
+		})
+	}
+}
+
+// TestPlanCapsEachSource is an electric first hybrid whose battery is far smaller than the miles it needs,
+// the battery is filled and the rest goes into the gas tank
+func TestPlanCapsEachSource(t *testing.T) {
+	h := HybridEngine{
+		GasEngine:      GasEngine{MPG: 40, Gallons: 0, TankGallons: 10},
+		ElectricEngine: ElectricEngine{MPKWh: 3, KWh: 1, BatteryKWh: 12},
+		Order:          ElectricFirst,
+	}
+	it, err := Plan(h, []float64{3, 200}, []Stop{{Name: "A", At: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Feasible {
+		t.Fatalf("infeasible: %s\n%s", it.Problem, it)
+	}
+	for _, step := range it.Steps {
+		for _, d := range step.Added {
+			if d.Source == Electricity && d.Amount > 12+tolerance {
+				t.Errorf("added %.2f kWh to a 12 kWh battery", d.Amount)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package vehicle
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var ErrUnknownKind = errors.New("vehicle: unknown engine type")
+
+// Engine kinds registered by this package
+const (
+	KindGas      = "gas"
+	KindElectric = "electric"
+	KindHybrid   = "hybrid"
+	KindHydrogen = "hydrogen"
+)
+
+// Params are an engine's settings as read from a config file, the "type" entry names the kind
+// e.g. {"type": "electric", "mpkwh": 3, "kwh": 10}
+type Params map[string]any
+
+// Factory builds an engine of one kind from its params
+type Factory func(p Params) (Engine, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+	kinds     map[reflect.Type]string
+}{factories: make(map[string]Factory), kinds: make(map[reflect.Type]string)}
+
+func init() {
+	Register(KindGas, GasEngine{}, JSONFactory[GasEngine]())
+	Register(KindElectric, ElectricEngine{}, JSONFactory[ElectricEngine]())
+	Register(KindHybrid, HybridEngine{}, JSONFactory[HybridEngine]())
+	Register(KindHydrogen, HydrogenEngine{}, JSONFactory[HydrogenEngine]())
+}
+
+// Register makes a kind of engine available to New and FromParams under name
+// proto is a zero value of the engine type, it lets KindOf name engines of that type
+// and registers the type with encoding/gob so fleets holding it can be saved
+// Like database/sql.Register it panics if the name or type is registered twice, it is meant to be called from init
+func Register(name string, proto Engine, f Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+	t := baseType(proto)
+	if _, ok := registry.factories[name]; ok {
+		panic("vehicle: Register called twice for engine type " + name)
+	}
+	if other, ok := registry.kinds[t]; ok {
+		panic(fmt.Sprintf("vehicle: %v is already registered as %s", t, other))
+	}
+	registry.factories[name] = f
+	registry.kinds[t] = name
+	gob.Register(reflect.Zero(t).Interface())
+}
+
+// Kinds returns the registered engine types, sorted
+func Kinds() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New builds an engine of the named kind
+func New(kind string, p Params) (Engine, error) {
+	registry.RLock()
+	f, ok := registry.factories[kind]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (registered: %v)", ErrUnknownKind, kind, Kinds())
+	}
+	e, err := f(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s engine: %w", kind, err)
+	}
+	return e, nil
+}
+
+// FromParams builds the engine named by p["type"]
+func FromParams(p Params) (Engine, error) {
+	kind, _ := p["type"].(string)
+	if kind == "" {
+		return nil, fmt.Errorf("%w: params have no \"type\"", ErrUnknownKind)
+	}
+	return New(kind, p)
+}
+
+// FromJSON builds an engine from a JSON object with a "type" field
+func FromJSON(data []byte) (Engine, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var p Params
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	return FromParams(p)
+}
+
+// KindOf returns the name e's type was registered under, engines behind a pointer count as their type
+func KindOf(e Engine) (string, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if kind, ok := registry.kinds[baseType(e)]; ok {
+		return kind, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnknownKind, e)
+}
+
+// ParamsOf is the reverse of FromParams, the engine's JSON fields plus its "type"
+// Numbers are json.Number and nested objects, such as the owner, are map[string]any
+func ParamsOf(e Engine) (Params, error) {
+	kind, err := KindOf(e)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // numbers stay json.Number, so a large owner ID is not rounded through float64
+	var p Params
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	p["type"] = kind
+	return p, nil
+}
+
+// JSONFactory returns a Factory that decodes params into T through its JSON field names
+// Params T does not know about are an error, so a typo in a config file is not silently ignored
+func JSONFactory[T Engine]() Factory {
+	return func(p Params) (Engine, error) {
+		fields := make(Params, len(p))
+		for k, v := range p {
+			if k != "type" {
+				fields[k] = v
+			}
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		var e T
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+}
+
+func baseType(e Engine) reflect.Type {
+	t := reflect.TypeOf(e)
+	if t != nil && t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,71 @@
+package vehicle
+
+import "errors"
+
+var ErrNoSources = errors.New("vehicle: engine does not report its energy sources")
+
+// Source is a kind of energy an engine runs on
+type Source string
+
+const (
+	Gasoline    Source = "gasoline"
+	Electricity Source = "electricity"
+	Hydrogen    Source = "hydrogen"
+)
+
+// Unit returns the unit amounts of the source are measured in
+func (s Source) Unit() string {
+	switch s {
+	case Gasoline:
+		return "gallons"
+	case Electricity:
+		return "kWh"
+	case Hydrogen:
+		return "kg"
+	}
+	return ""
+}
+
+// Draw is the share of a trip driven on one energy source and how much of that source it uses
+type Draw struct {
+	Source Source  `json:"source"`
+	Miles  float64 `json:"miles"`
+	Amount float64 `json:"amount"` // measured in Source.Unit()
+}
+
+// Drawer is implemented by engines that can say which energy sources a trip would use
+// Draw covers at most the miles the engine has left, a longer trip is cut short at its range
+type Drawer interface {
+	Draw(miles float64) ([]Draw, error)
+}
+
+// Sources reports which energy sources e would use to drive miles
+// It returns ErrNoSources for engines that do not implement Drawer
+func Sources(e Engine, miles float64) ([]Draw, error) {
+	d, ok := e.(Drawer) // type assertion, checks whether the value in the interface also has a Draw method
+	if !ok {
+		return nil, ErrNoSources
+	}
+	return d.Draw(miles)
+}
+
+// drawFrom is the Draw of a single source engine
+func drawFrom(source Source, efficiency, energy, miles float64) ([]Draw, error) {
+	left, err := milesFrom(efficiency, energy)
+	if err != nil {
+		return nil, err
+	}
+	miles = min(max(miles, 0), left)
+	if miles == 0 {
+		return nil, nil
+	}
+	return []Draw{{Source: source, Miles: miles, Amount: miles / efficiency}}, nil
+}
+
+func (g GasEngine) Draw(miles float64) ([]Draw, error) {
+	return drawFrom(Gasoline, g.MPG, g.Gallons, miles)
+}
+
+func (e ElectricEngine) Draw(miles float64) ([]Draw, error) {
+	return drawFrom(Electricity, e.MPKWh, e.KWh, miles)
+}
@@ -0,0 +1,161 @@
+package vehicle
+
+import "fmt"
+
+// Conversion factors, gallons are US gallons
+const (
+	KmPerMile       = 1.609344
+	LitresPerGallon = 3.785411784
+	KWhPerGallon    = 33.7 // energy in a gallon of gasoline, the basis of miles per gallon equivalent (MPGe)
+	KgPerGallon     = 1.0  // a kg of hydrogen holds about as much energy as a gallon of gasoline
+)
+
+// UnitSystem picks how distances, efficiencies and amounts are printed
+// Engines always store US units (miles, mpg, miles per kWh), the metric helpers convert at the edges
+type UnitSystem uint8
+
+const (
+	Imperial UnitSystem = iota // miles, mpg, gallons, miles per kWh
+	Metric                     // km, L/100km, litres, kWh/100km
+)
+
+func (u UnitSystem) String() string {
+	if u == Metric {
+		return "metric"
+	}
+	return "imperial"
+}
+
+func ParseUnitSystem(s string) (UnitSystem, error) {
+	switch s {
+	case "imperial", "us", "miles":
+		return Imperial, nil
+	case "metric", "si", "km":
+		return Metric, nil
+	}
+	return 0, fmt.Errorf("vehicle: unknown unit system %q (want imperial or metric)", s)
+}
+
+func MilesToKm(miles float64) float64 { return miles * KmPerMile }
+func KmToMiles(km float64) float64    { return km / KmPerMile }
+
+func GallonsToLitres(gallons float64) float64 { return gallons * LitresPerGallon }
+func LitresToGallons(litres float64) float64  { return litres / LitresPerGallon }
+
+// MPGToLPer100Km converts miles per gallon to litres per 100 km, the conversion is its own inverse
+func MPGToLPer100Km(mpg float64) float64 {
+	return 100 * LitresPerGallon / (mpg * KmPerMile)
+}
+
+func LPer100KmToMPG(lPer100Km float64) float64 {
+	return MPGToLPer100Km(lPer100Km)
+}
+
+// MPKWhToKWhPer100Km converts miles per kWh to kWh per 100 km, like mpg it is its own inverse
+func MPKWhToKWhPer100Km(mpkwh float64) float64 {
+	return 100 / (mpkwh * KmPerMile)
+}
+
+func KWhPer100KmToMPKWh(kWhPer100Km float64) float64 {
+	return MPKWhToKWhPer100Km(kWhPer100Km)
+}
+
+// KgPer100KmToMilesPerKg converts the metric hydrogen consumption to miles per kg
+func KgPer100KmToMilesPerKg(kgPer100Km float64) float64 {
+	return 100 / (kgPer100Km * KmPerMile)
+}
+
+// NewGasEngineMetric declares a gas engine from a consumption in L/100km and a tank level in litres
+func NewGasEngineMetric(lPer100Km, litres float64, owner EngineOwner) GasEngine {
+	return GasEngine{MPG: LPer100KmToMPG(lPer100Km), Gallons: LitresToGallons(litres), OwnerInfo: owner}
+}
+
+// NewElectricEngineMetric declares an electric engine from a consumption in kWh/100km and a battery level in kWh
+func NewElectricEngineMetric(kWhPer100Km, kWh float64, owner EngineOwner) ElectricEngine {
+	return ElectricEngine{MPKWh: KWhPer100KmToMPKWh(kWhPer100Km), KWh: kWh, OwnerInfo: owner}
+}
+
+// NewHydrogenEngineMetric declares a hydrogen engine from a consumption in kg/100km and a tank level in kg
+func NewHydrogenEngineMetric(kgPer100Km, kg float64, owner EngineOwner) HydrogenEngine {
+	return HydrogenEngine{MilesPerKg: KgPer100KmToMilesPerKg(kgPer100Km), Kg: kg, OwnerInfo: owner}
+}
+
+// FormatDistance prints a distance given in miles in the unit system u
+func FormatDistance(miles float64, u UnitSystem) string {
+	if u == Metric {
+		return fmt.Sprintf("%.1f km", MilesToKm(miles))
+	}
+	return fmt.Sprintf("%.1f miles", miles)
+}
+
+// FormatAmount prints an amount of a source, gasoline is shown in litres in metric
+func FormatAmount(amount float64, source Source, u UnitSystem) string {
+	if u == Metric && source == Gasoline {
+		return fmt.Sprintf("%.2f litres", GallonsToLitres(amount))
+	}
+	return fmt.Sprintf("%.2f %s", amount, source.Unit())
+}
+
+// FormatEfficiency prints how efficiently an engine uses a source, distance per unit or unit per 100 km
+func FormatEfficiency(milesPerUnit float64, source Source, u UnitSystem) string {
+	if u == Imperial {
+		if source == Gasoline {
+			return fmt.Sprintf("%.1f mpg", milesPerUnit)
+		}
+		return fmt.Sprintf("%.1f miles per %s", milesPerUnit, source.Unit())
+	}
+	switch source {
+	case Gasoline:
+		return fmt.Sprintf("%.1f L/100km", MPGToLPer100Km(milesPerUnit))
+	case Electricity:
+		return fmt.Sprintf("%.1f kWh/100km", MPKWhToKWhPer100Km(milesPerUnit))
+	}
+	return fmt.Sprintf("%.2f %s/100km", 100/MilesToKm(milesPerUnit), source.Unit())
+}
+
+// Format prints the verdict of CanDrive in the unit system u
+func (v Verdict) Format(u UnitSystem) string {
+	if v.Feasible {
+		return fmt.Sprintf("%s trip fits in %s of range, %s left after it", FormatDistance(v.Trip, u), FormatDistance(v.Range, u), FormatDistance(v.Remaining, u))
+	}
+	return fmt.Sprintf("%s trip is %s more than the %s of range", FormatDistance(v.Trip, u), FormatDistance(v.Shortfall, u), FormatDistance(v.Range, u))
+}
+
+// GallonsEquivalent converts an amount of source to the gallons of gasoline holding the same energy
+func GallonsEquivalent(source Source, amount float64) (float64, error) {
+	switch source {
+	case Gasoline:
+		return amount, nil
+	case Electricity:
+		return amount / KWhPerGallon, nil
+	case Hydrogen:
+		return amount / KgPerGallon, nil
+	}
+	return 0, fmt.Errorf("vehicle: no gallon equivalent for %q", source)
+}
+
+// MPGe is e's efficiency in miles per gallon of gasoline equivalent, so engines on different sources can be compared
+// It needs an engine that implements Refiller, a hybrid's MPGe follows its drain order over a 100 mile trip
+func MPGe(e Engine) (float64, error) {
+	r, ok := e.(Refiller)
+	if !ok {
+		return 0, fmt.Errorf("vehicle: %T does not report the energy it uses", e)
+	}
+	const miles = 100
+	draws, err := r.EnergyFor(miles)
+	if err != nil {
+		return 0, err
+	}
+	var gallons float64
+	for _, d := range draws {
+		g, err := GallonsEquivalent(d.Source, d.Amount)
+		if err != nil {
+			return 0, err
+		}
+		gallons += g
+	}
+	if gallons <= 0 {
+		return 0, fmt.Errorf("vehicle: %T uses no energy", e)
+	}
+	return miles / gallons, nil
+}
@@ -0,0 +1,67 @@
+// Package vehicle models engines, the people who own them and how far they can go
+package vehicle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrOverflow = errors.New("vehicle: range overflows float64")
+	ErrNegative = errors.New("vehicle: efficiency and energy cannot be negative")
+)
+
+/*
+
+	Structs and Interfaces
+	Declaration phrasing:
+	type StructName struct {
+		FieldName fieldType (values default to zero values if not initialized)
+		...
+	}
+
+	Names starting with an upper case letter are exported and can be used by other packages
+
+*/
+
+type GasEngine struct {
+	MPG         float64     `json:"mpg"`                    // miles per gallon
+	Gallons     float64     `json:"gallons"`                // fuel currently in the tank
+	TankGallons float64     `json:"tank_gallons,omitempty"` // tank size, 0 means unknown and refuelling is unlimited
+	OwnerInfo   EngineOwner `json:"owner"`                  // fields can be other structs, creating nested structs
+}
+
+// func (receiverName receiverType) MethodName(parameterName parameterType) returnType { ... }
+func (g GasEngine) MilesLeft() (float64, error) { // method with receiver of type GasEngine, directly associated with the struct, and can access its fields
+	return milesFrom(g.MPG, g.Gallons)
+}
+
+type ElectricEngine struct {
+	MPKWh      float64     `json:"mpkwh"`                 // miles per kilowatt hour
+	KWh        float64     `json:"kwh"`                   // charge currently in the battery
+	BatteryKWh float64     `json:"battery_kwh,omitempty"` // battery size, 0 means unknown and charging is unlimited
+	OwnerInfo  EngineOwner `json:"owner"`
+}
+
+func (e ElectricEngine) MilesLeft() (float64, error) { // method with receiver of type ElectricEngine
+	return milesFrom(e.MPKWh, e.KWh)
+}
+
+type Engine interface { // interface type, defines a set of methods that a type must implement to satisfy the interface
+	MilesLeft() (float64, error) // any type that has a MilesLeft method with this signature satisfies the Engine interface
+}
+
+// milesFrom multiplies an efficiency by the energy left
+// The result is a float64, so 25 mpg and 15 gallons no longer wrap around the way two uint8 values did,
+// and a result too large for float64 is reported as ErrOverflow instead of +Inf
+func milesFrom(efficiency, energy float64) (float64, error) {
+	if efficiency < 0 || energy < 0 {
+		return 0, ErrNegative
+	}
+	miles := efficiency * energy
+	if math.IsInf(miles, 0) || math.IsNaN(miles) {
+		return 0, fmt.Errorf("%w: %g × %g", ErrOverflow, efficiency, energy)
+	}
+	return miles, nil
+}
@@ -0,0 +1,110 @@
+package vehicle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrNegativeTrip = errors.New("vehicle: trip distance cannot be negative")
+	ErrInvalidTrip  = errors.New("vehicle: trip distance must be a finite number")
+)
+
+// finite reports whether x is an ordinary number, neither NaN nor infinite
+func finite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// CheckTrip rejects distances no engine can drive: negative ones with ErrNegativeTrip, NaN and infinity with ErrInvalidTrip
+func CheckTrip(miles float64) error {
+	if !finite(miles) {
+		return fmt.Errorf("%w: %g", ErrInvalidTrip, miles)
+	}
+	if miles < 0 {
+		return fmt.Errorf("%w: %g", ErrNegativeTrip, miles)
+	}
+	return nil
+}
+
+// Verdict is the answer to "can this engine make the trip", with the numbers behind it
+type Verdict struct {
+	Feasible  bool    `json:"feasible"`
+	Trip      float64 `json:"trip_miles"`
+	Range     float64 `json:"range_miles"`     // miles left before the trip
+	Remaining float64 `json:"remaining_miles"` // miles left after the trip, 0 when it is not feasible
+	Shortfall float64 `json:"shortfall_miles"` // miles the engine cannot cover, 0 when it is feasible
+	Needed    []Draw  `json:"needed,omitempty"`
+}
+
+// Refiller is implemented by engines that can say how much energy covers a given distance
+// Unlike Draw it does not look at the energy left, it answers "how much do I have to add to drive this far"
+type Refiller interface {
+	EnergyFor(miles float64) ([]Draw, error)
+}
+
+// CanDrive checks whether e can drive miles and returns the verdict instead of printing it
+// For engines that implement Refiller, Needed holds the energy to add to cover the shortfall
+func CanDrive(e Engine, miles float64) (Verdict, error) {
+	if err := CheckTrip(miles); err != nil {
+		return Verdict{}, err
+	}
+	left, err := e.MilesLeft()
+	if err != nil {
+		return Verdict{}, err
+	}
+	v := Verdict{Trip: miles, Range: left}
+	if miles <= left {
+		v.Feasible = true
+		v.Remaining = left - miles
+		return v, nil
+	}
+	v.Shortfall = miles - left
+	if r, ok := e.(Refiller); ok {
+		if v.Needed, err = r.EnergyFor(v.Shortfall); err != nil {
+			return Verdict{}, err
+		}
+	}
+	return v, nil
+}
+
+// energyFor is the EnergyFor of a single source engine
+func energyFor(source Source, efficiency, miles float64) ([]Draw, error) {
+	if efficiency <= 0 {
+		return nil, fmt.Errorf("vehicle: cannot cover %g miles on %s with an efficiency of %g", miles, source, efficiency)
+	}
+	return []Draw{{Source: source, Miles: miles, Amount: miles / efficiency}}, nil
+}
+
+func (g GasEngine) EnergyFor(miles float64) ([]Draw, error) {
+	return energyFor(Gasoline, g.MPG, miles)
+}
+
+func (e ElectricEngine) EnergyFor(miles float64) ([]Draw, error) {
+	return energyFor(Electricity, e.MPKWh, miles)
+}
+
+func (h HydrogenEngine) EnergyFor(miles float64) ([]Draw, error) {
+	return energyFor(Hydrogen, h.MilesPerKg, miles)
+}
+
+// EnergyFor fills up the part the drain order uses first, a blended hybrid splits the miles evenly
+func (h HybridEngine) EnergyFor(miles float64) ([]Draw, error) {
+	switch h.Order {
+	case ElectricFirst:
+		return h.ElectricEngine.EnergyFor(miles)
+	case GasFirst:
+		return h.GasEngine.EnergyFor(miles)
+	case Blended:
+		electric, err := h.ElectricEngine.EnergyFor(miles / 2)
+		if err != nil {
+			return nil, err
+		}
+		gas, err := h.GasEngine.EnergyFor(miles / 2)
+		if err != nil {
+			return nil, err
+		}
+		return append(electric, gas...), nil
+	}
+	return nil, fmt.Errorf("vehicle: unknown drain order %v", h.Order)
+}